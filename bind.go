@@ -0,0 +1,292 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is a binding error of a single struct field
+type FieldError struct {
+	Field  string
+	Source string
+	Name   string
+	Value  string
+	Err    error
+}
+
+// BindError aggregates all field errors of a binding
+type BindError struct {
+	Errors []*FieldError
+}
+
+// bindSource is a request value source with its struct tag name
+type bindSource struct {
+	tag    string
+	values func(req *http.Request, name string) ([]string, error)
+}
+
+const (
+	defaultTag = "default"
+
+	// maxFormMemory is the max memory of the multipart form values and files,
+	// the rest of the files are stored on disk
+	maxFormMemory = 32 << 20
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	bindSources = []bindSource{
+		{tag: "path", values: pathValues},
+		{tag: "query", values: queryValues},
+		{tag: "header", values: headerValues},
+		{tag: "form", values: formValues},
+	}
+)
+
+// Error implements error interface
+func (e *FieldError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s %q: %s", e.Source, e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s %q: invalid value %q: %s", e.Source, e.Name, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Error implements error interface
+func (e *BindError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// StatusCode returns the HTTP status code for the binding errors
+func (e *BindError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Bind fills the struct pointed by dst from the path params, query string,
+// headers and form fields of the request using `path`, `query`, `header` and
+// `form` struct tags. The form fields are read from both URL encoded and
+// multipart form bodies. When a field has multiple tags, the first source having a
+// value wins in the same order. The `default` tag sets the value of a field
+// when none of its sources has a value; for slices the default values are comma
+// separated.
+//
+// Supported field types are strings, booleans, integers, floats,
+// time.Duration, encoding.TextUnmarshaler implementations like time.Time,
+// pointers and slices of them. All conversion failures are returned together
+// as *BindError.
+func Bind(req *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("bind destination must be a non-nil struct pointer")
+	}
+
+	bindErr := &BindError{}
+	bindStruct(req, v.Elem(), bindErr)
+	if len(bindErr.Errors) > 0 {
+		return bindErr
+	}
+	return nil
+}
+
+func bindStruct(req *http.Request, v reflect.Value, bindErr *BindError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			bindStruct(req, v.Field(i), bindErr)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		bindField(req, f, v.Field(i), bindErr)
+	}
+}
+
+func bindField(
+	req *http.Request,
+	f reflect.StructField,
+	v reflect.Value,
+	bindErr *BindError,
+) {
+	for _, source := range bindSources {
+		name, ok := f.Tag.Lookup(source.tag)
+		if !ok {
+			continue
+		}
+		values, err := source.values(req, name)
+		if err != nil {
+			bindErr.Errors = append(bindErr.Errors, &FieldError{
+				Field:  f.Name,
+				Source: source.tag,
+				Name:   name,
+				Err:    err,
+			})
+			return
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := setValues(v, values); err != nil {
+			bindErr.Errors = append(bindErr.Errors, &FieldError{
+				Field:  f.Name,
+				Source: source.tag,
+				Name:   name,
+				Value:  strings.Join(values, ","),
+				Err:    err,
+			})
+		}
+		return
+	}
+
+	if def, ok := f.Tag.Lookup(defaultTag); ok {
+		values := []string{def}
+		if isMultiValue(v) {
+			values = strings.Split(def, ",")
+		}
+		if err := setValues(v, values); err != nil {
+			bindErr.Errors = append(bindErr.Errors, &FieldError{
+				Field:  f.Name,
+				Source: defaultTag,
+				Name:   f.Name,
+				Value:  def,
+				Err:    err,
+			})
+		}
+	}
+}
+
+// isMultiValue reports whether the value accepts multiple values
+func isMultiValue(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice &&
+		!reflect.PtrTo(v.Type()).Implements(textUnmarshalerType)
+}
+
+func setValues(v reflect.Value, values []string) error {
+	if isMultiValue(v) {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return numError(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+func numError(err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+	return err
+}
+
+func pathValues(req *http.Request, name string) ([]string, error) {
+	params, _ := ParamsFromContext(req.Context())
+	if v, ok := params[name]; ok {
+		return []string{v}, nil
+	}
+	return nil, nil
+}
+
+func queryValues(req *http.Request, name string) ([]string, error) {
+	return req.URL.Query()[name], nil
+}
+
+func headerValues(req *http.Request, name string) ([]string, error) {
+	return req.Header.Values(name), nil
+}
+
+func formValues(req *http.Request, name string) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := req.ParseMultipartForm(maxFormMemory); err != nil {
+			return nil, err
+		}
+		return req.MultipartForm.Value[name], nil
+	}
+	if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+	return req.PostForm[name], nil
+}
@@ -0,0 +1,164 @@
+package compass
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	type pagination struct {
+		Page    int `query:"page" default:"1"`
+		PerPage int `query:"per_page" default:"20"`
+	}
+	type request struct {
+		pagination
+		ID       int64         `path:"id"`
+		Tenant   string        `header:"X-Tenant"`
+		Tags     []string      `query:"tag" default:"a,b"`
+		IDs      []uint        `query:"ids"`
+		Draft    *bool         `query:"draft"`
+		Timeout  time.Duration `query:"timeout" default:"1s"`
+		Since    time.Time     `query:"since"`
+		Title    string        `form:"title"`
+		Locale   string        `query:"locale" header:"Accept-Language"`
+		Ratio    float64       `query:"ratio"`
+		Untagged string
+		internal string
+	}
+
+	t.Run("binds values from all sources", func(t *testing.T) {
+		form := url.Values{"title": {"Hello"}}
+		req := httptest.NewRequest(
+			"POST",
+			"http://example.com/posts/7?page=3&ids=1&ids=2&draft=true"+
+				"&since=2021-02-03T04:05:06Z&ratio=0.5",
+			strings.NewReader(form.Encode()),
+		)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("Accept-Language", "tr")
+		req = req.WithContext(WithParams(req.Context(), map[string]string{
+			"id": "7",
+		}))
+
+		var got request
+		if err := Bind(req, &got); err != nil {
+			t.Fatalf("Bind() must not fail, got %s", err)
+		}
+
+		draft := true
+		want := request{
+			pagination: pagination{Page: 3, PerPage: 20},
+			ID:         7,
+			Tenant:     "acme",
+			Tags:       []string{"a", "b"},
+			IDs:        []uint{1, 2},
+			Draft:      &draft,
+			Timeout:    time.Second,
+			Since:      time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+			Title:      "Hello",
+			Locale:     "tr",
+			Ratio:      0.5,
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("want: %+v, got: %+v", want, got)
+		}
+	})
+
+	t.Run("binds multipart form values", func(t *testing.T) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("title", "Hello")
+		_ = mw.WriteField("tag", "x")
+		_ = mw.Close()
+		req := httptest.NewRequest("POST", "http://example.com/posts", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		var got struct {
+			Title string   `form:"title"`
+			Tags  []string `form:"tag"`
+		}
+		if err := Bind(req, &got); err != nil {
+			t.Fatalf("Bind() must not fail, got %s", err)
+		}
+		if got.Title != "Hello" || !reflect.DeepEqual(got.Tags, []string{"x"}) {
+			t.Fatalf("want multipart form values, got %+v", got)
+		}
+	})
+
+	t.Run("reports malformed multipart forms", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "http://example.com/posts", strings.NewReader("--x"))
+		req.Header.Set("Content-Type", "multipart/form-data")
+
+		var got struct {
+			Title string `form:"title"`
+		}
+		if err := Bind(req, &got); err == nil {
+			t.Fatalf("Bind() must fail for malformed multipart forms")
+		}
+	})
+
+	t.Run("aggregates conversion errors", func(t *testing.T) {
+		req := httptest.NewRequest(
+			"GET",
+			"http://example.com/posts?page=x&ids=1&ids=-2&timeout=forever",
+			nil,
+		)
+
+		var got request
+		err := Bind(req, &got)
+
+		var bindErr *BindError
+		if !errors.As(err, &bindErr) {
+			t.Fatalf("must return BindError, got %v", err)
+		}
+		if bindErr.StatusCode() != http.StatusBadRequest {
+			t.Fatalf("binding errors must map to bad request")
+		}
+		want := `query "page": invalid value "x": invalid syntax; ` +
+			`query "ids": invalid value "1,-2": invalid syntax; ` +
+			`query "timeout": invalid value "forever": ` +
+			`time: invalid duration "forever"`
+		if err.Error() != want {
+			t.Fatalf("want err(%s), got err(%s)", want, err)
+		}
+		if bindErr.Errors[0].Field != "Page" {
+			t.Fatalf("field errors must have field names")
+		}
+	})
+
+	t.Run("reports invalid defaults and unsupported types", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		var dst struct {
+			Limit int            `default:"many"`
+			Meta  map[string]int `query:"meta"`
+		}
+		req.URL.RawQuery = "meta=1"
+
+		err := Bind(req, &dst)
+		want := `default "Limit": invalid value "many": invalid syntax; ` +
+			`query "meta": invalid value "1": ` +
+			`unsupported field type map[string]int`
+		if err == nil || err.Error() != want {
+			t.Fatalf("want err(%s), got err(%v)", want, err)
+		}
+	})
+
+	t.Run("rejects invalid destinations", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		var s struct{}
+		for _, dst := range []interface{}{nil, s, new(int), (*struct{})(nil)} {
+			if err := Bind(req, dst); err == nil {
+				t.Fatalf("Bind(%T) must fail", dst)
+			}
+		}
+	})
+}
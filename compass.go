@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"runtime/debug"
 	"strings"
 
 	chandler "github.com/mustafaturan/compass/handler"
@@ -21,23 +22,51 @@ type Router interface {
 	http.Handler
 
 	// Get registers handler for GET method
-	Get(path string, handler http.Handler) error
+	Get(path string, handler http.Handler, options ...RouteOption) error
 	// Head registers handler for HEAD method
-	Head(path string, handler http.Handler) error
+	Head(path string, handler http.Handler, options ...RouteOption) error
 	// Post registers handler for POST method
-	Post(path string, handler http.Handler) error
+	Post(path string, handler http.Handler, options ...RouteOption) error
 	// Put registers handler for PUT method
-	Put(path string, handler http.Handler) error
+	Put(path string, handler http.Handler, options ...RouteOption) error
 	// Patch registers handler for PATCH method
-	Patch(path string, handler http.Handler) error
+	Patch(path string, handler http.Handler, options ...RouteOption) error
 	// Delete registers handler for DELETE method
-	Delete(path string, handler http.Handler) error
+	Delete(path string, handler http.Handler, options ...RouteOption) error
 	// Connect registers handler for CONNECT method
-	Connect(path string, handler http.Handler) error
+	Connect(path string, handler http.Handler, options ...RouteOption) error
 	// Options registers handler for OPTIONS method
-	Options(path string, handler http.Handler) error
+	Options(path string, handler http.Handler, options ...RouteOption) error
 	// Trace registers handler for TRACE method
-	Trace(path string, handler http.Handler) error
+	Trace(path string, handler http.Handler, options ...RouteOption) error
+
+	// Routes returns the registered routes in the registration order
+	Routes() []RouteInfo
+
+	// Group returns a Router which registers the routes under the path prefix
+	// and applies the given route options before the options of each route
+	Group(prefix string, options ...RouteOption) Router
+
+	// Disable makes the named route respond with 503 until it is enabled
+	Disable(name string) error
+	// Enable makes the named route serve requests again
+	Enable(name string) error
+}
+
+// RouteInfo describes a registered route, the Pattern is empty for the
+// requests which don't match any route
+type RouteInfo struct {
+	Method   string
+	Pattern  string
+	Name     string
+	Metadata map[string]interface{}
+	Consumes []string
+	Produces []string
+
+	// Predicates are the names of the request conditions of the route
+	Predicates []string
+
+	settings map[interface{}]interface{}
 }
 
 // router is an implementation of Router
@@ -45,6 +74,12 @@ type router struct {
 	interceptors []cinterceptor.Interceptor
 	matcher      *cmatcher.Matcher
 
+	// routes keeps the registered routes in the registration order
+	routes []RouteInfo
+
+	// names keeps the named routes to guarantee the uniqueness of names
+	names map[string]*chandler.Handler
+
 	// Schemes allows access to the provided schemes only
 	// The default value catches `http` and `https` schemes
 	schemes map[string]struct{}
@@ -56,33 +91,93 @@ type router struct {
 	// NotFound http handler
 	notfound http.Handler
 
-	// InternalServerError http handler for panic recovery
+	// InternalServerError http handler
 	internalservererror http.Handler
+
+	// handlers are the handlers of the other router generated status codes,
+	// 421 falls back to NotFound handler unless a handler is set for it
+	handlers map[int]http.Handler
+
+	// maxURILength is the max length of the request URIs, 0 is unlimited
+	maxURILength int
+
+	// panichandler handles the recovered panics
+	panichandler PanicHandler
+
+	// errormapper maps the errors returned by HandlerFunc handlers
+	errormapper ErrorMapper
+
+	// problem enables RFC 7807 problem responses for router errors
+	problem bool
 }
 
 // Option is a router option
 type Option func(*router) error
 
+// RouteOption is a route option which is applied on route registration
+type RouteOption func(*chandler.Handler) error
+
+// PanicHandler handles the panics recovered by the router with the recovered
+// value and the stack trace of the panic
+type PanicHandler func(
+	rw http.ResponseWriter,
+	req *http.Request,
+	recovered interface{},
+	stack []byte,
+)
+
 type ctxKey int8
 
 const (
 	// CtxParams params context key
 	CtxParams = ctxKey(0)
 
+	// CtxRoute matched route context key
+	CtxRoute = ctxKey(1)
+
+	// ctxErrorMapper error mapper context key
+	ctxErrorMapper = ctxKey(2)
+
+	// ctxRouter router context key
+	ctxRouter = ctxKey(3)
+
 	// matchall char to match any hostname or scheme
 	matchall = "*"
 )
 
+// statusCodes are the router generated status codes which can have handlers
+// other than 404 and 500
+var statusCodes = []int{
+	http.StatusBadRequest,
+	http.StatusMethodNotAllowed,
+	http.StatusNotAcceptable,
+	http.StatusRequestEntityTooLarge,
+	http.StatusRequestURITooLong,
+	http.StatusUnsupportedMediaType,
+	http.StatusMisdirectedRequest,
+	http.StatusNotImplemented,
+	http.StatusServiceUnavailable,
+}
+
 // New returns a new Router with default handlers
 func New(options ...Option) (Router, error) {
 	r := &router{
 		interceptors:        make([]cinterceptor.Interceptor, 0),
 		matcher:             cmatcher.New(),
+		names:               make(map[string]*chandler.Handler),
 		schemes:             map[string]struct{}{matchall: {}},
 		hostnames:           map[string]struct{}{matchall: {}},
-		notfound:            http.NotFoundHandler(),
+		notfound:            chandler.NotFound{},
 		internalservererror: chandler.InternalServerError{},
+		handlers:            make(map[int]http.Handler),
 	}
+	for _, code := range statusCodes {
+		if code != http.StatusMisdirectedRequest {
+			r.handlers[code] = chandler.Status{Code: code}
+		}
+	}
+	r.panichandler = r.handlePanic
+	r.errormapper = r.mapError
 
 	for _, o := range options {
 		if err := o(r); err != nil {
@@ -121,8 +216,10 @@ func WithHostnames(hostnames ...string) Option {
 	}
 }
 
-// WithHandler option registers default handlers for NotFound and
-// InternalServerError error status codes
+// WithHandler option registers default handlers for the router generated
+// status codes: 400 (param errors), 404, 405, 406, 413, 414, 415, 421, 500,
+// 501 and 503.
+// The handlers can access the reason of the status code with Reason function.
 func WithHandler(statusCode int, h http.Handler) Option {
 	return func(r *router) error {
 		if h == nil {
@@ -134,12 +231,61 @@ func WithHandler(statusCode int, h http.Handler) Option {
 		case 500:
 			r.internalservererror = h
 		default:
-			return fmt.Errorf("can't set a default handler for status code %d", statusCode)
+			if !isStatusCode(statusCode) {
+				return fmt.Errorf("can't set a default handler for status code %d", statusCode)
+			}
+			r.handlers[statusCode] = h
 		}
 		return nil
 	}
 }
 
+// WithMaxURILength option makes the router respond with 414 for the requests
+// with longer URIs than the given length
+func WithMaxURILength(length int) Option {
+	return func(r *router) error {
+		if length < 1 {
+			return errors.New("max uri length must be positive")
+		}
+		r.maxURILength = length
+		return nil
+	}
+}
+
+// WithProblemDetails option makes the router generated errors respond with
+// RFC 7807 problem documents, the format is negotiated against the Accept
+// header with HTML and plain text fallbacks. The handlers set by WithHandler
+// are kept as they are.
+func WithProblemDetails() Option {
+	return func(r *router) error {
+		r.problem = true
+		if _, ok := r.notfound.(chandler.NotFound); ok {
+			r.notfound = chandler.NotFound{Problem: true}
+		}
+		if _, ok := r.internalservererror.(chandler.InternalServerError); ok {
+			r.internalservererror = chandler.InternalServerError{Problem: true}
+		}
+		for code, h := range r.handlers {
+			if _, ok := h.(chandler.Status); ok {
+				r.handlers[code] = chandler.Status{Code: code, Problem: true}
+			}
+		}
+		return nil
+	}
+}
+
+// WithPanicHandler option sets the handler for the panics recovered by the
+// router, the default panic handler serves the InternalServerError handler
+func WithPanicHandler(h PanicHandler) Option {
+	return func(r *router) error {
+		if h == nil {
+			return errors.New("panic handler can't be nil")
+		}
+		r.panichandler = h
+		return nil
+	}
+}
+
 // WithInterceptors appends a interceptor.Interceptor to the chain. Interceptor
 // can be used to intercept or otherwise modify requests and/or responses, and
 // are executed in the order that they are applied to the Router.
@@ -150,86 +296,408 @@ func WithInterceptors(interceptors ...cinterceptor.Interceptor) Option {
 	}
 }
 
+// Name option sets a unique name for the route
+func Name(name string) RouteOption {
+	return func(h *chandler.Handler) error {
+		if name == "" {
+			return errors.New("route name can't be empty")
+		}
+		h.Name = name
+		return nil
+	}
+}
+
+// Interceptors option appends route level interceptors to the chain. Route
+// level interceptors are executed after the router level interceptors in the
+// order that they are applied to the route.
+func Interceptors(interceptors ...cinterceptor.Interceptor) RouteOption {
+	return func(h *chandler.Handler) error {
+		for _, i := range interceptors {
+			if i == nil {
+				return errors.New("interceptor can't be nil")
+			}
+		}
+		h.Interceptors = append(h.Interceptors, interceptors...)
+		return nil
+	}
+}
+
+// Metadata option sets a route attribute which is exposed with RouteInfo
+func Metadata(key string, value interface{}) RouteOption {
+	return func(h *chandler.Handler) error {
+		if key == "" {
+			return errors.New("metadata key can't be empty")
+		}
+		h.Metadata[key] = value
+		return nil
+	}
+}
+
 // Params provides access to compass params
 func Params(ctx context.Context) map[string]string {
 	return ctx.Value(CtxParams).(map[string]string)
 }
 
-// ServeHTTP implements http.Handler interface with interceptors
-func (r *router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	defer r.internalservererror.ServeHTTP(rw, req)
+// Route returns the matched route of the request from the context
+func Route(ctx context.Context) RouteInfo {
+	route, _ := ctx.Value(CtxRoute).(RouteInfo)
+	return route
+}
+
+// AllowedMethods returns the sorted methods which have a registered route for
+// the request path, it returns nil for the requests which aren't served by a
+// compass router
+func AllowedMethods(req *http.Request) []string {
+	r, ok := req.Context().Value(ctxRouter).(*router)
+	if !ok {
+		return nil
+	}
+	return r.matcher.Methods(r.segments(req))
+}
+
+// Setting returns the route setting of the key, the settings are stored by
+// the route options of the packages with chandler.Handler.SetSetting
+func (ri RouteInfo) Setting(key interface{}) interface{} {
+	return ri.settings[key]
+}
 
-	var h http.Handler
-	var params map[string]string
+// Found reports whether the route info belongs to a registered route
+func (ri RouteInfo) Found() bool {
+	return ri.Pattern != ""
+}
 
-	if !r.isAllowedScheme(req.URL.Scheme) || !r.isAllowedHostname(req.URL.Hostname()) {
-		h, params = r.notfound, make(map[string]string)
-	} else {
-		h, params = r.match(req)
+// ServeHTTP implements http.Handler interface with interceptors
+func (r *router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w := cinterceptor.WrapResponseWriter(rw)
+	defer func() {
+		r.recover(w, req, recover())
+	}()
+
+	h, params := r.notfound, make(map[string]string)
+	route := RouteInfo{Method: req.Method}
+	ctx := req.Context()
+
+	matched, segments, err := r.match(req)
+	switch {
+	case err != nil:
+		h = r.handlers[err.Code]
+		ctx = chandler.WithReason(ctx, err.Error())
+		if err.Code == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", strings.Join(r.matcher.Methods(segments), ", "))
+		}
+	case matched != nil:
+		limitBody(matched, rw, req)
+		h, params = matched.HTTPHandler, matched.Params(segments)
+		route = newRouteInfo(req.Method, matched)
+		for i := len(matched.Interceptors) - 1; i >= 0; i-- {
+			h = matched.Interceptors[i].Middleware(h)
+		}
 	}
 
-	// Attach params to request with context
-	ctx := context.WithValue(req.Context(), CtxParams, params)
+	// Attach params and the matched route to request with context
+	ctx = context.WithValue(ctx, CtxParams, params)
+	ctx = context.WithValue(ctx, CtxRoute, route)
+	ctx = context.WithValue(ctx, ctxRouter, r)
+	ctx = withErrorMapper(ctx, r.errormapper)
+	ctx = chandler.RecordRequestID(ctx)
 	req = req.WithContext(ctx)
 
 	for i := len(r.interceptors) - 1; i >= 0; i-- {
 		h = r.interceptors[i].Middleware(h)
 	}
 
-	h.ServeHTTP(rw, req)
+	h.ServeHTTP(w, req)
+}
+
+// recover passes the recovered panics to the panic handler, the response
+// writes of the panic handler are discarded when the header is already sent
+// and a plain 500 is written when the panic handler writes nothing.
+// http.ErrAbortHandler panics are re-panicked to let the server abort the
+// response.
+func (r *router) recover(
+	w cinterceptor.ResponseWriter,
+	req *http.Request,
+	recovered interface{},
+) {
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+
+	stack := debug.Stack()
+	// the request ids are set to the contexts of the interceptors
+	if id := chandler.RecordedRequestID(req.Context()); id != "" &&
+		chandler.RequestID(req.Context()) == "" {
+		req = req.WithContext(chandler.WithRequestID(req.Context(), id))
+	}
+	var rw http.ResponseWriter = w
+	if w.Written() {
+		rw = discardWriter{header: make(http.Header)}
+	}
+	r.panichandler(rw, req, recovered, stack)
+	if !w.Written() {
+		http.Error(w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+	}
+}
+
+func (r *router) handlePanic(
+	rw http.ResponseWriter,
+	req *http.Request,
+	recovered interface{},
+	stack []byte,
+) {
+	r.internalservererror.ServeHTTP(rw, req)
 }
 
 // Get registers handler for GET method
-func (r *router) Get(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodGet, path, handler)
+func (r *router) Get(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodGet, path, handler, options...)
 }
 
 // Head registers handler for HEAD method
-func (r *router) Head(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodHead, path, handler)
+func (r *router) Head(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodHead, path, handler, options...)
 }
 
 // Post registers handler for POST method
-func (r *router) Post(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodPost, path, handler)
+func (r *router) Post(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodPost, path, handler, options...)
 }
 
 // Put registers handler for PUT method
-func (r *router) Put(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodPut, path, handler)
+func (r *router) Put(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodPut, path, handler, options...)
 }
 
 // Patch registers handler for PATCH method
-func (r *router) Patch(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodPatch, path, handler)
+func (r *router) Patch(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodPatch, path, handler, options...)
 }
 
 // Delete registers handler for DELETE method
-func (r *router) Delete(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodDelete, path, handler)
+func (r *router) Delete(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodDelete, path, handler, options...)
 }
 
 // Connect registers handler for CONNECT method
-func (r *router) Connect(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodConnect, path, handler)
+func (r *router) Connect(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodConnect, path, handler, options...)
 }
 
 // Options registers handler for OPTIONS method
-func (r *router) Options(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodOptions, path, handler)
+func (r *router) Options(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodOptions, path, handler, options...)
 }
 
 // Trace registers handler for TRACE method
-func (r *router) Trace(path string, handler http.Handler) error {
-	return r.registerHandler(http.MethodTrace, path, handler)
+func (r *router) Trace(
+	path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
+	return r.registerHandler(http.MethodTrace, path, handler, options...)
 }
 
-func (r *router) registerHandler(method, path string, handler http.Handler) error {
+func (r *router) registerHandler(
+	method, path string,
+	handler http.Handler,
+	options ...RouteOption,
+) error {
 	h, err := chandler.New(path, handler)
 	if err != nil {
 		return err
 	}
-	return r.matcher.Register(method, h)
+	for _, o := range options {
+		if err := o(h); err != nil {
+			return err
+		}
+	}
+	if _, exists := r.names[h.Name]; exists && h.Name != "" {
+		return fmt.Errorf("route name %q is already registered", h.Name)
+	}
+	if err := r.matcher.Register(method, h); err != nil {
+		return err
+	}
+	if h.Name != "" {
+		r.names[h.Name] = h
+	}
+	r.routes = append(r.routes, newRouteInfo(method, h))
+	return nil
+}
+
+// Routes returns the registered routes in the registration order
+func (r *router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(r.routes))
+	for i, route := range r.routes {
+		routes[i] = route.clone()
+	}
+	return routes
+}
+
+// newRouteInfo returns the route info of the handler, the route info doesn't
+// share memory with the handler
+func newRouteInfo(method string, h *chandler.Handler) RouteInfo {
+	route := RouteInfo{
+		Method:   method,
+		Pattern:  h.Path(),
+		Name:     h.Name,
+		Metadata: h.Metadata,
+		Consumes: h.Consumes,
+		Produces: h.Produces,
+		settings: h.Settings(),
+	}
+	for _, p := range h.Predicates {
+		route.Predicates = append(route.Predicates, p.Name)
+	}
+	return route.clone()
+}
+
+// clone returns a copy of the route info, the settings are shared since they
+// are read-only after the registration
+func (ri RouteInfo) clone() RouteInfo {
+	if ri.Metadata != nil {
+		metadata := make(map[string]interface{}, len(ri.Metadata))
+		for k, v := range ri.Metadata {
+			metadata[k] = v
+		}
+		ri.Metadata = metadata
+	}
+	ri.Consumes = cloneStrings(ri.Consumes)
+	ri.Produces = cloneStrings(ri.Produces)
+	ri.Predicates = cloneStrings(ri.Predicates)
+	return ri
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(values)), values...)
+}
+
+// Disable makes the named route respond with 503 until it is enabled
+func (r *router) Disable(name string) error {
+	h, ok := r.names[name]
+	if !ok {
+		return fmt.Errorf("route name %q is not registered", name)
+	}
+	h.Disable()
+	return nil
+}
+
+// Enable makes the named route serve requests again
+func (r *router) Enable(name string) error {
+	h, ok := r.names[name]
+	if !ok {
+		return fmt.Errorf("route name %q is not registered", name)
+	}
+	h.Enable()
+	return nil
+}
+
+// match finds the handler and the path segments of the request, it returns a
+// StatusError for the router generated status codes and no handler when no
+// route is found
+func (r *router) match(
+	req *http.Request,
+) (*chandler.Handler, []string, *StatusError) {
+	if r.maxURILength > 0 && len(req.URL.RequestURI()) > r.maxURILength {
+		return nil, nil, statusError(http.StatusRequestURITooLong,
+			"uri is longer than %d bytes", r.maxURILength)
+	}
+	if !r.isAllowedScheme(req.URL.Scheme) {
+		return nil, nil, nil
+	}
+	if !r.isAllowedHostname(req.URL.Hostname()) {
+		if _, ok := r.handlers[http.StatusMisdirectedRequest]; !ok {
+			return nil, nil, nil
+		}
+		return nil, nil, statusError(http.StatusMisdirectedRequest,
+			"hostname %q is not served", req.URL.Hostname())
+	}
+	if !r.matcher.Supports(req.Method) {
+		return nil, nil, statusError(http.StatusNotImplemented,
+			"method %s is not implemented", req.Method)
+	}
+
+	segments := r.segments(req)
+	h, err := r.matcher.Match(req, segments)
+	switch err {
+	case nil:
+	case cmatcher.ErrUnsupportedMediaType:
+		return nil, nil, statusError(http.StatusUnsupportedMediaType,
+			"content type %q is not supported", req.Header.Get("Content-Type"))
+	case cmatcher.ErrNotAcceptable:
+		return nil, nil, statusError(http.StatusNotAcceptable,
+			"none of the accepted content types %q are produced",
+			req.Header.Get("Accept"))
+	case cmatcher.ErrNoMatch:
+		return nil, nil, nil
+	default:
+		if len(r.matcher.Methods(segments)) > 0 {
+			return nil, segments, statusError(http.StatusMethodNotAllowed,
+				"method %s is not allowed", req.Method)
+		}
+		return nil, nil, nil
+	}
+	if h.Disabled() {
+		return nil, nil, statusError(http.StatusServiceUnavailable,
+			"route %q is disabled", h.Name)
+	}
+	if err := checkBodySize(h, req); err != nil {
+		return nil, nil, err
+	}
+	return h, segments, nil
+}
+
+func statusError(code int, format string, args ...interface{}) *StatusError {
+	return &StatusError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+func isStatusCode(code int) bool {
+	for _, c := range statusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *router) isAllowedHostname(hostname string) bool {
@@ -248,11 +716,22 @@ func (r *router) isAllowedScheme(scheme string) bool {
 	return hasMatchAll
 }
 
-func (r *router) match(req *http.Request) (http.Handler, map[string]string) {
-	path := req.URL.EscapedPath()
-	segments := strings.Split(path[1:], "/")
-	if h, found := r.matcher.Find(req.Method, segments); found {
-		return h.HTTPHandler, h.Params(segments)
-	}
-	return r.notfound, make(map[string]string)
+func (r *router) segments(req *http.Request) []string {
+	path := strings.TrimPrefix(req.URL.EscapedPath(), "/")
+	return strings.Split(path, "/")
+}
+
+// discardWriter is a http.ResponseWriter which discards all writes
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
+
+func (w discardWriter) WriteHeader(int) {}
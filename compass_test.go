@@ -10,6 +10,8 @@ import (
 	"testing"
 
 	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+	"github.com/mustafaturan/compass/interceptor/requestid"
 )
 
 func TestNew(t *testing.T) {
@@ -139,9 +141,12 @@ func TestWithHandler(t *testing.T) {
 		{404, nil, "handler can't be nil"},
 		{404, http.NotFoundHandler(), ""},
 		{500, chandler.InternalServerError{}, ""},
+		{405, chandler.Status{Code: 405}, ""},
+		{421, http.NotFoundHandler(), ""},
+		{401, http.NotFoundHandler(), "can't set a default handler for status code 401"},
 	}
 
-	r := &router{}
+	r := &router{handlers: make(map[int]http.Handler)}
 
 	for _, test := range tests {
 		err := WithHandler(test.statusCode, test.handlerFunc)(r)
@@ -171,6 +176,173 @@ func TestWithInterceptors(t *testing.T) {
 	})
 }
 
+func TestRouteOptions(t *testing.T) {
+	r, _ := New()
+	first, second := &fakeInterceptor{"first"}, &fakeInterceptor{"second"}
+
+	err := r.Get("/posts", fakeHandler{"ok"}, Name("posts"),
+		Interceptors(first, second))
+	if err != nil {
+		t.Fatalf("registration with route options must not fail, got %s", err)
+	}
+
+	t.Run("applies route options", func(t *testing.T) {
+		h, _ := r.(*router).matcher.Find(http.MethodGet, []string{"posts"})
+		if h.Name != "posts" {
+			t.Fatalf("route name must be set, got %s", h.Name)
+		}
+		if len(h.Interceptors) != 2 || h.Interceptors[0] != first {
+			t.Fatalf("route interceptors registered in incorrect order")
+		}
+	})
+
+	t.Run("executes route interceptors", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/posts", nil)
+		r.ServeHTTP(rw, req)
+		if first.name != "called" || second.name != "called" {
+			t.Fatalf("route interceptors must be executed on the serve")
+		}
+	})
+
+	t.Run("rejects invalid route options", func(t *testing.T) {
+		tests := []struct {
+			option RouteOption
+			err    string
+		}{
+			{Name(""), "route name can't be empty"},
+			{Name("posts"), `route name "posts" is already registered`},
+			{Interceptors(nil), "interceptor can't be nil"},
+		}
+		for _, test := range tests {
+			err := r.Get("/comments", fakeHandler{"ok"}, test.option)
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("want err(%s), got err(%v)", test.err, err)
+			}
+		}
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	r, _ := New()
+	_ = r.Get("/posts", fakeHandler{"ok"}, Name("posts.list"))
+	_ = r.Post("/posts/:id", fakeHandler{"ok"}, Metadata("owner", "blog"))
+
+	if err := r.Get("/x", fakeHandler{"ok"}, Metadata("", 1)); err == nil {
+		t.Fatalf("metadata with empty key must be rejected")
+	}
+
+	want := []RouteInfo{
+		{
+			Method:   http.MethodGet,
+			Pattern:  "/posts",
+			Name:     "posts.list",
+			Metadata: map[string]interface{}{},
+		},
+		{
+			Method:   http.MethodPost,
+			Pattern:  "/posts/:id",
+			Metadata: map[string]interface{}{"owner": "blog"},
+		},
+	}
+	if got := r.Routes(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("want: %+v, got: %+v", want, got)
+	}
+
+	t.Run("returns copies", func(t *testing.T) {
+		r, _ := New()
+		_ = r.Post("/posts", fakeHandler{"ok"}, Metadata("owner", "blog"), Consumes("application/json"))
+
+		got := r.Routes()
+		got[0].Metadata["owner"] = "other"
+		got[0].Consumes[0] = "text/plain"
+
+		route := r.Routes()[0]
+		if route.Metadata["owner"] != "blog" || route.Consumes[0] != "application/json" {
+			t.Fatalf("routes must not share memory: %+v", route)
+		}
+		req := httptest.NewRequest("POST", "http://example.com/posts", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("routing must not change, got status code %d", rw.Code)
+		}
+	})
+}
+
+func TestRoute(t *testing.T) {
+	var got RouteInfo
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = Route(req.Context())
+	})
+
+	interceptor := &routeInterceptor{}
+	r, _ := New(WithInterceptors(interceptor))
+	_ = r.Get("/posts/:id", h, Name("posts.show"))
+
+	t.Run("matched route", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/posts/1", nil)
+		r.ServeHTTP(rw, req)
+
+		want := RouteInfo{
+			Method:   http.MethodGet,
+			Pattern:  "/posts/:id",
+			Name:     "posts.show",
+			Metadata: map[string]interface{}{},
+		}
+		if !reflect.DeepEqual(want, got) || !got.Found() {
+			t.Fatalf("want: %+v, got: %+v", want, got)
+		}
+		if !reflect.DeepEqual(want, interceptor.route) {
+			t.Fatalf("interceptors must access the route: %+v", interceptor.route)
+		}
+	})
+
+	t.Run("not found route", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "http://example.com/posts/1", nil)
+		r.ServeHTTP(rw, req)
+
+		want := RouteInfo{Method: http.MethodPost}
+		if !reflect.DeepEqual(want, interceptor.route) || interceptor.route.Found() {
+			t.Fatalf("want: %+v, got: %+v", want, interceptor.route)
+		}
+	})
+
+	t.Run("without route", func(t *testing.T) {
+		if Route(context.Background()).Found() {
+			t.Fatalf("route must not be found without a routed request")
+		}
+	})
+}
+
+func TestRouteSettings(t *testing.T) {
+	type key int8
+	setting := func(h *chandler.Handler) error {
+		h.SetSetting(key(0), true)
+		return nil
+	}
+
+	var got RouteInfo
+	r, _ := New()
+	_ = r.Get("/posts", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = Route(req.Context())
+	}), setting, Metadata("0", false))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/posts", nil))
+
+	if got.Setting(key(0)) != true || r.Routes()[0].Setting(key(0)) != true {
+		t.Fatalf("route settings must be accessible")
+	}
+	if got.Setting("0") != nil || got.Metadata["0"] != false {
+		t.Fatalf("route settings must not collide with metadata")
+	}
+	if (RouteInfo{}).Setting(key(0)) != nil {
+		t.Fatalf("not found route must not have settings")
+	}
+}
+
 func TestParams(t *testing.T) {
 	expected := map[string]string{"test": "val"}
 	ctx := context.Background()
@@ -311,6 +483,172 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+func TestPanicRecovery(t *testing.T) {
+	panicking := func(written bool) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if written {
+				rw.WriteHeader(http.StatusAccepted)
+			}
+			panic("ohh no!")
+		})
+	}
+
+	t.Run("serves internal server error by default", func(t *testing.T) {
+		r, _ := New()
+		_ = r.Get("/panic", panicking(false))
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+		if rw.Code != http.StatusInternalServerError {
+			t.Fatalf("want status code 500, but got %d", rw.Code)
+		}
+	})
+
+	t.Run("passes panic value and stack to the panic handler", func(t *testing.T) {
+		var gotRecovered interface{}
+		var gotStack []byte
+		var gotRoute RouteInfo
+		r, _ := New(WithPanicHandler(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+			recovered interface{},
+			stack []byte,
+		) {
+			gotRecovered, gotStack = recovered, stack
+			gotRoute = Route(req.Context())
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		_ = r.Get("/panic", panicking(false))
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+		if gotRecovered != "ohh no!" {
+			t.Fatalf("panic handler must receive the panic value, got %v", gotRecovered)
+		}
+		if !strings.Contains(string(gotStack), "TestPanicRecovery") {
+			t.Fatalf("panic handler must receive the stack, got %s", gotStack)
+		}
+		if gotRoute.Pattern != "/panic" {
+			t.Fatalf("panic handler must receive the routed request")
+		}
+		if rw.Code != http.StatusServiceUnavailable {
+			t.Fatalf("want status code 503, but got %d", rw.Code)
+		}
+	})
+
+	t.Run("skips writing when header is already sent", func(t *testing.T) {
+		called := false
+		r, _ := New(WithPanicHandler(func(
+			rw http.ResponseWriter,
+			req *http.Request,
+			recovered interface{},
+			stack []byte,
+		) {
+			called = true
+			http.Error(rw, "failed", http.StatusInternalServerError)
+		}))
+		_ = r.Get("/panic", panicking(true))
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+		if !called {
+			t.Fatalf("panic handler must be called")
+		}
+		if rw.Code != http.StatusAccepted || rw.Body.Len() != 0 {
+			t.Fatalf("panic handler writes must be discarded")
+		}
+	})
+
+	t.Run("writes 500 when the handlers write nothing", func(t *testing.T) {
+		// the 500 handlers of the earlier versions recovered the panics
+		legacy := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if err := recover(); err != nil {
+				http.Error(rw, "recovered", http.StatusInternalServerError)
+			}
+		})
+		silent := func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte) {}
+		tests := map[string]Option{
+			"500 handler":   WithHandler(http.StatusInternalServerError, legacy),
+			"panic handler": WithPanicHandler(silent),
+		}
+		for name, option := range tests {
+			r, _ := New(option)
+			_ = r.Get("/panic", panicking(false))
+
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+			want := "Internal Server Error\n"
+			if rw.Code != http.StatusInternalServerError || rw.Body.String() != want {
+				t.Fatalf("%s: want 500 %q, but got %d %q", name, want, rw.Code, rw.Body.String())
+			}
+		}
+	})
+
+	t.Run("serves the request id of the interceptors", func(t *testing.T) {
+		rid, _ := requestid.New()
+		r, _ := New(WithInterceptors(rid))
+		_ = r.Get("/panic", panicking(false))
+
+		req := httptest.NewRequest("GET", "http://example.com/panic", nil)
+		req.Header.Set(requestid.DefaultHeader, "abc")
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		want := "Internal Server Error\nrequest id: abc\n"
+		if rw.Code != http.StatusInternalServerError || rw.Body.String() != want {
+			t.Fatalf("want 500 %q, but got %d %q", want, rw.Code, rw.Body.String())
+		}
+	})
+
+	t.Run("re-panics on abort handler", func(t *testing.T) {
+		r, _ := New()
+		_ = r.Get("/abort", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Fatalf("must re-panic with http.ErrAbortHandler, got %v", recovered)
+			}
+		}()
+		r.ServeHTTP(
+			httptest.NewRecorder(),
+			httptest.NewRequest("GET", "http://example.com/abort", nil),
+		)
+	})
+
+	t.Run("recovers panics of the interceptor chain building", func(t *testing.T) {
+		r, _ := New(WithInterceptors(cinterceptor.Func(func(h http.Handler) http.Handler {
+			panic("ohh no!")
+		})))
+		_ = r.Get("/panic", panicking(false))
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+		if rw.Code != http.StatusInternalServerError {
+			t.Fatalf("want status code 500, but got %d", rw.Code)
+		}
+	})
+
+	t.Run("serves requests with an empty path", func(t *testing.T) {
+		r, _ := New()
+		_ = r.Get("/users", panicking(false))
+
+		req, _ := http.NewRequest("CONNECT", "http://example.com", nil)
+		req.URL.Path = ""
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		if rw.Code != http.StatusNotFound {
+			t.Fatalf("want status code 404, but got %d", rw.Code)
+		}
+	})
+
+	t.Run("rejects nil panic handler", func(t *testing.T) {
+		if _, err := New(WithPanicHandler(nil)); err == nil {
+			t.Fatalf("nil panic handler must be rejected")
+		}
+	})
+}
+
 func TestMethodRegistrations(t *testing.T) {
 	r, _ := New()
 	getPath, getHandler := "/test-get-path", fakeHandler{"ok"}
@@ -372,6 +710,146 @@ func TestMethodRegistrations(t *testing.T) {
 	})
 }
 
+func TestStatusHandlers(t *testing.T) {
+	reason := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+		_, _ = rw.Write([]byte(Reason(req.Context())))
+	})
+	failing := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) error {
+		return &ParamError{Name: "id", Err: ErrParamNotFound}
+	})
+
+	r, _ := New(WithHostnames("example.com"), WithMaxURILength(32))
+	_ = r.Get("/posts", fakeHandler{"ok"}, Name("posts"))
+	_ = r.Post("/posts", fakeHandler{"ok"})
+	_ = r.Get("/failing", failing)
+	custom, _ := New(
+		WithHostnames("example.com"),
+		WithHandler(http.StatusBadRequest, reason),
+		WithHandler(http.StatusMisdirectedRequest, reason),
+		WithHandler(http.StatusMethodNotAllowed, reason),
+	)
+	_ = custom.Get("/posts", fakeHandler{"ok"})
+	_ = custom.Get("/failing", failing)
+
+	tests := []struct {
+		name       string
+		router     Router
+		method     string
+		url        string
+		statusCode int
+		body       string
+		allow      string
+	}{
+		{
+			"uri too long", r, "GET", "http://example.com/posts?q=0123456789abcdef0123456789",
+			http.StatusRequestURITooLong, "uri is longer than 32 bytes\n", "",
+		},
+		{
+			"unknown method", r, "PROPFIND", "http://example.com/posts",
+			http.StatusNotImplemented, "method PROPFIND is not implemented\n", "",
+		},
+		{
+			"method not allowed", r, "DELETE", "http://example.com/posts",
+			http.StatusMethodNotAllowed, "method DELETE is not allowed\n", "GET, POST",
+		},
+		{
+			"misdirected host without handler", r, "GET", "http://example.org/posts",
+			http.StatusNotFound, "Not Found\n", "",
+		},
+		{
+			"bad params", r, "GET", "http://example.com/failing",
+			http.StatusBadRequest, "param \"id\": param not found\n", "",
+		},
+		{
+			"custom misdirected host", custom, "GET", "http://example.org/posts",
+			http.StatusTeapot, "hostname \"example.org\" is not served", "",
+		},
+		{
+			"custom method not allowed", custom, "PUT", "http://example.com/posts",
+			http.StatusTeapot, "method PUT is not allowed", "GET",
+		},
+		{
+			"custom bad params", custom, "GET", "http://example.com/failing",
+			http.StatusTeapot, "param \"id\": param not found", "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(test.method, test.url, nil)
+			test.router.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+			if got := rw.Header().Get("Allow"); got != test.allow {
+				t.Fatalf("want Allow header %q, but got %q", test.allow, got)
+			}
+		})
+	}
+
+	t.Run("disabled routes", func(t *testing.T) {
+		serve := func() *httptest.ResponseRecorder {
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/posts", nil))
+			return rw
+		}
+
+		if err := r.Disable("posts"); err != nil {
+			t.Fatalf("disabling a named route must not fail, got %s", err)
+		}
+		if rw := serve(); rw.Code != http.StatusServiceUnavailable ||
+			rw.Body.String() != "route \"posts\" is disabled\n" {
+			t.Fatalf("disabled route must respond with 503, got %d", rw.Code)
+		}
+
+		if err := r.Enable("posts"); err != nil {
+			t.Fatalf("enabling a named route must not fail, got %s", err)
+		}
+		if rw := serve(); rw.Code != http.StatusOK {
+			t.Fatalf("enabled route must respond with 200, got %d", rw.Code)
+		}
+
+		if r.Disable("missing") == nil || r.Enable("missing") == nil {
+			t.Fatalf("unknown route names must return error")
+		}
+	})
+
+	t.Run("rejects invalid max uri lengths", func(t *testing.T) {
+		if _, err := New(WithMaxURILength(0)); err == nil {
+			t.Fatalf("max uri length must be positive")
+		}
+	})
+}
+
+func TestAllowedMethods(t *testing.T) {
+	var got []string
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = AllowedMethods(req)
+	})
+	r, _ := New()
+	_ = r.Get("/posts/:id", h)
+	_ = r.Put("/posts/:id", h)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/posts/1", nil))
+	t.Run("returns the registered methods of the path", func(t *testing.T) {
+		if strings.Join(got, ",") != "GET,PUT" {
+			t.Fatalf("want GET and PUT methods, got %v", got)
+		}
+	})
+
+	t.Run("returns nil outside of the router", func(t *testing.T) {
+		if AllowedMethods(httptest.NewRequest("GET", "/posts/1", nil)) != nil {
+			t.Fatalf("want nil methods")
+		}
+	})
+}
+
 type fakeInterceptor struct {
 	name string
 }
@@ -383,6 +861,17 @@ func (m *fakeInterceptor) Middleware(h http.Handler) http.Handler {
 	})
 }
 
+type routeInterceptor struct {
+	route RouteInfo
+}
+
+func (m *routeInterceptor) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		m.route = Route(req.Context())
+		h.ServeHTTP(rw, req)
+	})
+}
+
 type fakeHandler struct {
 	bodyText string
 }
@@ -0,0 +1,114 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	chandler "github.com/mustafaturan/compass/handler"
+	"github.com/mustafaturan/compass/internal/negotiate"
+)
+
+// bodyLimit is a request body which is limited by http.MaxBytesReader, its
+// errors are reported with 413 status code after the limit is exceeded
+type bodyLimit struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+// Consumes route option restricts the request content types of the route, the
+// requests with a body of another content type are responded with 415. The
+// media types can have wildcards like `image/*`. The routes of the same method
+// and path can be registered with different media types to serve them with
+// different handlers.
+func Consumes(mediaTypes ...string) RouteOption {
+	return func(h *chandler.Handler) error {
+		types, err := mediaTypesOf(mediaTypes)
+		if err != nil {
+			return err
+		}
+		h.Consumes = types
+		return nil
+	}
+}
+
+// Produces route option declares the response content types of the route, the
+// requests which don't accept any of them are responded with 406. The route
+// producing the media type preferred most by the Accept header is served when
+// more than one route is registered for the same method and path.
+func Produces(mediaTypes ...string) RouteOption {
+	return func(h *chandler.Handler) error {
+		types, err := mediaTypesOf(mediaTypes)
+		if err != nil {
+			return err
+		}
+		h.Produces = types
+		return nil
+	}
+}
+
+// MaxBodyBytes route option limits the request body size of the route with
+// http.MaxBytesReader, the requests with a longer Content-Length are
+// responded with 413 and the body reads fail with a 413 HTTPError after the
+// limit is exceeded
+func MaxBodyBytes(n int64) RouteOption {
+	return func(h *chandler.Handler) error {
+		if n < 1 {
+			return errors.New("max body bytes must be positive")
+		}
+		h.MaxBodyBytes = n
+		return nil
+	}
+}
+
+// checkBodySize checks the Content-Length of the request against the body
+// limit of the handler
+func checkBodySize(h *chandler.Handler, req *http.Request) *StatusError {
+	if h.MaxBodyBytes > 0 && req.ContentLength > h.MaxBodyBytes {
+		return statusError(http.StatusRequestEntityTooLarge,
+			"body is longer than %d bytes", h.MaxBodyBytes)
+	}
+	return nil
+}
+
+// limitBody wraps the request body with http.MaxBytesReader when the handler
+// has a body limit
+func limitBody(h *chandler.Handler, rw http.ResponseWriter, req *http.Request) {
+	if h.MaxBodyBytes < 1 || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = &bodyLimit{
+		ReadCloser: http.MaxBytesReader(rw, req.Body, h.MaxBodyBytes),
+		limit:      h.MaxBodyBytes,
+	}
+}
+
+// Read implements io.Reader interface
+func (b *bodyLimit) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		err = &StatusError{Code: http.StatusRequestEntityTooLarge, Err: err}
+	}
+	return n, err
+}
+
+func mediaTypesOf(mediaTypes []string) ([]string, error) {
+	if len(mediaTypes) == 0 {
+		return nil, errors.New("media types can't be empty")
+	}
+	types := make([]string, len(mediaTypes))
+	for i, m := range mediaTypes {
+		types[i] = negotiate.MediaType(m)
+		if types[i] == "" {
+			return nil, fmt.Errorf("media type %q is invalid", m)
+		}
+	}
+	return types, nil
+}
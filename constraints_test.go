@@ -0,0 +1,178 @@
+package compass
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	echo := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) error {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		_, _ = rw.Write(body)
+		return nil
+	})
+
+	r, _ := New()
+	_ = r.Post("/posts", echo,
+		Consumes("application/json", "text/*"),
+		Produces("application/json"),
+		MaxBodyBytes(8),
+	)
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		accept      string
+		chunked     bool
+		statusCode  int
+		want        string
+	}{
+		{
+			"matching request", `{"a":1}`, "application/json; charset=utf-8", "",
+			false, http.StatusOK, `{"a":1}`,
+		},
+		{
+			"wildcard content type", "hello", "text/plain", "application/*",
+			false, http.StatusOK, "hello",
+		},
+		{
+			"request without body", "", "", "application/json",
+			false, http.StatusOK, "",
+		},
+		{
+			"unsupported content type", "a=1", "application/x-www-form-urlencoded", "",
+			false, http.StatusUnsupportedMediaType,
+			"content type \"application/x-www-form-urlencoded\" is not supported\n",
+		},
+		{
+			"missing content type", "hello", "", "",
+			false, http.StatusUnsupportedMediaType,
+			"content type \"\" is not supported\n",
+		},
+		{
+			"unacceptable request", "", "", "text/html",
+			false, http.StatusNotAcceptable,
+			"none of the accepted content types \"text/html\" are produced\n",
+		},
+		{
+			"long content length", "0123456789", "text/plain", "",
+			false, http.StatusRequestEntityTooLarge,
+			"body is longer than 8 bytes\n",
+		},
+		{
+			"long chunked body", "0123456789", "text/plain", "",
+			true, http.StatusRequestEntityTooLarge,
+			"http: request body too large\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://example.com/posts",
+				strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			if test.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Body.String() != test.want {
+				t.Fatalf("want body %q, but got %q", test.want, rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("rejects invalid constraints", func(t *testing.T) {
+		options := []RouteOption{
+			Consumes(),
+			Produces("json"),
+			MaxBodyBytes(0),
+		}
+		for _, o := range options {
+			if err := r.Get("/invalid", echo, o); err == nil {
+				t.Fatalf("invalid constraint must be rejected")
+			}
+		}
+	})
+
+	t.Run("exposes media types to introspection", func(t *testing.T) {
+		route := r.Routes()[0]
+		if strings.Join(route.Consumes, ",") != "application/json,text/*" ||
+			strings.Join(route.Produces, ",") != "application/json" {
+			t.Fatalf("want media types in route info, got %+v", route)
+		}
+	})
+}
+
+func TestContentNegotiation(t *testing.T) {
+	r, _ := New()
+	_ = r.Get("/reports/:id", fakeHandler{"json"}, Produces("application/json"))
+	_ = r.Get("/reports/:id", fakeHandler{"csv"}, Produces("text/csv"))
+	_ = r.Post("/reports", fakeHandler{"from json"}, Consumes("application/json"))
+	_ = r.Post("/reports", fakeHandler{"from protobuf"}, Consumes("application/protobuf"))
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		accept      string
+		statusCode  int
+		body        string
+	}{
+		{"GET", "/reports/1", "", "text/csv", http.StatusOK, "csv"},
+		{"GET", "/reports/1", "", "application/json", http.StatusOK, "json"},
+		{"GET", "/reports/1", "", "image/png", http.StatusNotAcceptable,
+			"none of the accepted content types \"image/png\" are produced\n"},
+		{"POST", "/reports", "application/protobuf", "", http.StatusOK, "from protobuf"},
+		{"POST", "/reports", "application/json", "", http.StatusOK, "from json"},
+		{"POST", "/reports", "text/plain", "", http.StatusUnsupportedMediaType,
+			"content type \"text/plain\" is not supported\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.contentType+test.accept, func(t *testing.T) {
+			body := strings.NewReader("")
+			if test.method == "POST" {
+				body = strings.NewReader("body")
+			}
+			req := httptest.NewRequest(test.method, "http://example.com"+test.path, body)
+			req.Header.Set("Content-Type", test.contentType)
+			req.Header.Set("Accept", test.accept)
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("rejects duplicate media types", func(t *testing.T) {
+		if err := r.Get("/reports/:id", fakeHandler{"csv"}, Produces("text/csv")); err == nil {
+			t.Fatalf("duplicate route must be rejected")
+		}
+		if len(r.Routes()) != 4 {
+			t.Fatalf("want 4 routes, got %d", len(r.Routes()))
+		}
+	})
+}
@@ -28,16 +28,27 @@ Init router with options
 		// register not found handler
 		compass.WithHandler(404, http.NotFoundHandler()),
 
-		// register internal server error handler
+		// register internal server error handler, it is served by the default
+		// panic handler when a panic is recovered. The panics are already
+		// recovered by the router, so the handler must not call recover() which
+		// returns nil; a plain 500 is written when the handler writes nothing
 		compass.WithHandler(500, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-			if err := recover(); err != nil {
-				http.Error(rw,
-					http.StatusText(http.StatusInternalServerError),
-					http.StatusInternalServerError,
-				)
-			}
+			http.Error(rw,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
 		})),
 
+		// or handle the recovered panics with the panic value and the stack, the
+		// response writes are discarded if the headers were already sent
+		compass.WithPanicHandler(func(rw http.ResponseWriter, req *http.Request, recovered interface{}, stack []byte) {
+			log.Printf("panic: %v\n%s", recovered, stack)
+			http.Error(rw,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		}),
+
 		// register interceptors(middlewares)
 		compass.WithInterceptors(interceptor1, interceptor2, interceptor3),
 	)
@@ -55,7 +66,7 @@ Init router with options
 	// <Method>: Get, Head, Post, Put, Patch, Delete, Connect, Options, Trace
 	// <path>: routing path with params
 	// <http.Handler>: any implementation of net/http.Handler
-	router.<Method>(<path>, <http.Handler>)
+	router.<Method>(<path>, <http.Handler>, <compass.RouteOption>...)
 
 **Path examples:**
 
@@ -66,6 +77,139 @@ Init router with options
 	"/posts/:id/reviews" -> params: id
 	"/posts/:id/reviews/:reviewID" -> params: id, reviewID
 
+**Route options:**
+
+	// name the route, names must be unique per router
+	router.Get("/posts/:id", showPost, compass.Name("posts.show"))
+
+	// register route level interceptors, they are executed after the router
+	// level interceptors
+	router.Post("/posts", createPost, compass.Interceptors(auth, audit))
+
+### Disabling Routes
+
+Named routes can be disabled at runtime, the disabled routes respond with 503
+until they are enabled again:
+
+	_ = r.Post("/orders", ordersHandler, compass.Name("create-order"))
+
+	err := r.Disable("create-order")
+	err = r.Enable("create-order")
+
+### Request Constraints
+
+The content types and the body size limits of the routes are declared on
+registration and checked before the handlers run. The requests with another
+`Content-Type` are responded with 415, the requests which don't accept any of
+the produced types with 406 and the requests with a longer `Content-Length`
+with 413. The request bodies are wrapped with `http.MaxBytesReader`:
+
+	_ = r.Post("/posts", createPost,
+		compass.Consumes("application/json"),
+		compass.Produces("application/json"),
+		compass.MaxBodyBytes(1<<20),
+	)
+
+### Content Negotiation
+
+More than one handler can be registered for the same method and path with
+different media types. The handler is selected by the `Content-Type` of the
+request body and the `Accept` header q-values after the path matches, the
+requests which none of the handlers fit are responded with 415 or 406:
+
+	_ = r.Get("/reports/:id", reportJSON, compass.Produces("application/json"))
+	_ = r.Get("/reports/:id", reportCSV, compass.Produces("text/csv"))
+
+	_ = r.Post("/reports", createFromJSON, compass.Consumes("application/json"))
+	_ = r.Post("/reports", createFromProto, compass.Consumes("application/protobuf"))
+
+### Request Predicates
+
+The routes can match only the requests with a header value, a query param
+value or a custom condition. The routes of the same method and path are
+evaluated in the registration order and the requests fall through to the next
+route when a predicate doesn't hold. The predicates are listed in the route
+introspection:
+
+	_ = r.Get("/items", itemsV2, compass.Header("X-API-Version", "2"))
+	_ = r.Get("/items", itemsXML, compass.Query("format", "xml"))
+	_ = r.Get("/items", itemsBeta, compass.When("beta", isBetaUser))
+	_ = r.Get("/items", items)
+
+### API Versioning
+
+`versioning` package routes the same paths to different handlers by the API
+version. The version can come from a path prefix, a vendor media type of the
+`Accept` header or a request header. The deprecated versions respond with
+`Deprecation` and `Sunset` headers:
+
+	import (
+		"github.com/mustafaturan/compass/versioning"
+		...
+	)
+
+	v, _ := versioning.New(
+		versioning.MediaType("acme"), // or versioning.Path(), versioning.Header("X-API-Version")
+		versioning.WithDefault("1"),
+		versioning.WithDeprecated("1", deprecatedAt, sunsetAt),
+	)
+
+	// Accept: application/vnd.acme.v2+json
+	v2 := v.Router(r, "2")
+	_ = v2.Get("/items", itemsV2)
+
+	// or on a single route
+	_ = r.Get("/items", itemsV1, v.Version("1"))
+
+The routes of `v.Router(r, "2")` are registered under `/v2` prefix with the
+path strategy.
+
+### Declarative Routes
+
+Routes can be defined outside of the Go code as JSON, handlers and
+interceptors are referenced by their names in a `compass.Registry`:
+
+	[
+		{"method": "GET", "path": "/posts", "handler": "listPosts"},
+		{
+			"method": "GET",
+			"path": "/posts/:id",
+			"handler": "showPost",
+			"name": "posts.show",
+			"interceptors": ["auth"]
+		}
+	]
+
+	registry := compass.Registry{
+		Handlers: map[string]http.Handler{
+			"listPosts": listPosts,
+			"showPost":  showPost,
+		},
+		Interceptors: map[string]interceptor.Interceptor{"auth": auth},
+	}
+
+	f, _ := os.Open("routes.json")
+	defer f.Close()
+
+	// all definitions are validated before the registration and all errors
+	// are reported together with their line numbers
+	router, err := compass.New(compass.LoadRoutes(f, registry))
+
+### Route Introspection
+
+Registered routes can be listed with their metadata in the registration order:
+
+	router.Get("/posts", listPosts, compass.Metadata("owner", "blog-team"))
+
+	for _, route := range router.Routes() {
+		fmt.Println(route.Method, route.Pattern, route.Name, route.Metadata)
+	}
+
+### OpenAPI
+
+The `openapi` package generates OpenAPI 3.0 documents from the registered
+routes, see the package documentation for details.
+
 ### Serving
 
 	router := compass.New()
@@ -88,6 +232,112 @@ function can be used:
 	// returns map[string]string
 	params := compass.Params(ctx)
 
+`ParamsFromContext` doesn't panic outside of a compass routed request and
+provides typed accessors which return `*compass.ParamError` on failures:
+
+	params, ok := compass.ParamsFromContext(ctx)
+
+	id, err := params.Int("id") // Int, Int64, Uint, Bool, UUID, String
+	publishedAt, err := params.Time("date", "2006-01-02")
+
+To test handlers without a router, params can be attached to a context:
+
+	ctx = compass.WithParams(ctx, map[string]string{"id": "1"})
+
+### Accessing The Matched Route
+
+The matched route is attached to request's context next to the params, so
+interceptors can use the route pattern instead of the concrete path for logs
+and metrics:
+
+	route := compass.Route(ctx)
+	route.Method   // GET
+	route.Pattern  // /posts/:id
+	route.Name     // posts.show
+	route.Metadata // map[string]interface{}
+
+	// requests which don't match any route have an empty pattern
+	route.Found() // false
+
+### Binding Request Values
+
+`Bind` fills a struct from path params, query string, headers and URL encoded
+or multipart form fields using struct tags. Values are converted to the field types, slices accept
+multiple values and `default` tag is used when the request has no value:
+
+	type listComments struct {
+		PostID  int64    `path:"id"`
+		Page    int      `query:"page" default:"1"`
+		Tags    []string `query:"tag"`
+		Tenant  string   `header:"X-Tenant"`
+		Comment string   `form:"comment"`
+	}
+
+	var input listComments
+	if err := compass.Bind(req, &input); err != nil {
+		// *compass.BindError aggregates all field errors and reports 400 as its
+		// StatusCode()
+	}
+
+### Handling Errors
+
+`HandlerFunc` handlers return errors instead of writing error responses. The
+returned errors are turned into responses by the `ErrorMapper`. The default
+mapper uses the status code of the first `HTTPError` in the error chain,
+exposes the error message for 4xx codes and serves the internal server error
+handler for the rest:
+
+	func showPost(rw http.ResponseWriter, req *http.Request) error {
+		params, _ := compass.ParamsFromContext(req.Context())
+		id, err := params.Int64("id")
+		if err != nil {
+			return err // *compass.ParamError responds with 400
+		}
+		post, err := posts.Find(id)
+		if errors.Is(err, errNoPost) {
+			return &compass.StatusError{Code: http.StatusNotFound, Err: err}
+		}
+		if err != nil {
+			return fmt.Errorf("finding post: %w", err) // 500
+		}
+		return json.NewEncoder(rw).Encode(post)
+	}
+
+	r, err := compass.New(compass.WithErrorMapper(mapper))
+	_ = r.Get("/posts/:id", compass.HandlerFunc(showPost))
+
+### Problem Details
+
+`WithProblemDetails` option makes the router generated errors like 404, 400
+param errors and 500 respond with RFC 7807 problem documents. The format is
+negotiated against the `Accept` header, `application/problem+json` is served
+by default with HTML and plain text fallbacks:
+
+	r, err := compass.New(compass.WithProblemDetails())
+
+	{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"instance": "/posts/42",
+		"request_id": "01F8MECHZX3TBDSZ7XRADM79XV"
+	}
+
+`ProblemErrorMapper` writes problem documents for the errors returned by the
+`HandlerFunc` handlers of a route group and `handler.WriteProblem` writes them
+from the custom handlers.
+
+### Route Groups
+
+`Group` registers routes under a path prefix and applies the given route
+options to all of them, the options of the routes are applied after the group
+options:
+
+	api := r.Group("/api", compass.MapErrors(jsonErrors))
+	_ = api.Get("/posts", compass.HandlerFunc(listPosts))
+	admin := api.Group("/admin", compass.Interceptors(adminOnly))
+	_ = admin.Delete("/posts/:id", compass.HandlerFunc(deletePost))
+
 ### Interceptors
 
 Interceptors are basically middlewares. The interceptors are compatible with
@@ -138,5 +388,309 @@ Option 2) Use home-ready implementation of Interceptor function
 	// init router with interceptor/middleware
 	router := compass.New(router.WithInterceptors(myMiddleware))
 
+### Built-in Interceptors
+
+#### Metrics
+
+`interceptor/metrics` records request count, in-flight requests, latency and
+response size labeled by method, matched route pattern and status class, and
+serves them in Prometheus text exposition format without external
+dependencies:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/metrics"
+		...
+	)
+
+	m, _ := metrics.New(metrics.WithNamespace("blog"))
+	router, _ := compass.New(compass.WithInterceptors(m))
+	router.Get("/metrics", m.Handler())
+
+#### Access Log
+
+`interceptor/accesslog` writes one structured line per request in JSON or
+logfmt format including the route pattern, params, status, bytes, duration,
+client IP and request ID:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/accesslog"
+		...
+	)
+
+	a, _ := accesslog.New(
+		// default: JSON lines to os.Stdout, or use accesslog.WithLogger
+		accesslog.WithLogger(accesslog.NewWriterLogger(os.Stderr, accesslog.Logfmt)),
+		// log 10% of the requests
+		accesslog.WithSampleRate(0.1),
+		// replace the values with [REDACTED], the params are also replaced in the path
+		accesslog.WithRedaction("params.token", "client_ip"),
+		// don't log the health checks
+		accesslog.WithSkip("/healthz"),
+	)
+	router, _ := compass.New(compass.WithInterceptors(a))
+
+#### Request ID
+
+`interceptor/requestid` accepts a valid incoming `X-Request-ID` or generates a
+sortable unique id, stores it in the request context and echoes it on the
+response. The default not found and internal server error responses include
+the request id so the user reports can be correlated:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/requestid"
+		...
+	)
+
+	rid, _ := requestid.New(
+		requestid.WithHeader("X-Request-ID"), // default
+		requestid.WithMaxLength(64),          // default
+	)
+	router, _ := compass.New(compass.WithInterceptors(rid))
+
+	// access the request id
+	id := requestid.FromContext(req.Context())
+
+#### CORS
+
+`interceptor/cors` answers the preflight requests itself with the methods which
+are registered on the router for the request path. Origins can be exact,
+subdomain wildcards, `*` or a predicate. The route groups can have their own
+policies by their path prefixes:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/cors"
+		...
+	)
+
+	c, _ := cors.New(
+		cors.WithOrigins("https://example.com", "https://*.example.com"),
+		cors.WithHeaders("Content-Type", "Authorization"),
+		cors.WithExposedHeaders("X-Request-ID"),
+		cors.WithCredentials(),
+		cors.WithMaxAge(10*time.Minute),
+		cors.WithGroup("/public", cors.WithOrigins("*")),
+	)
+	router, _ := compass.New(compass.WithInterceptors(c))
+
+`compass.AllowedMethods(req)` returns the registered methods of the request
+path for the custom interceptors.
+
+#### Rate Limit
+
+`interceptor/ratelimit` limits the requests per key with a token bucket. The
+requests are keyed by the client IP by default, `KeyByHeader`, `KeyByRoute` or
+a custom `KeyFunc` can be used instead. The state is kept in memory unless a
+shared `Store` is given. The responses have `RateLimit-Limit`,
+`RateLimit-Remaining` and `RateLimit-Reset` headers and the rejected ones also
+have `Retry-After` header:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/ratelimit"
+		...
+	)
+
+	// 10 requests per minute with bursts up to 5 requests per API key
+	rl, _ := ratelimit.New(
+		ratelimit.Limit{Rate: 10, Period: time.Minute, Burst: 5},
+		ratelimit.WithKey(ratelimit.KeyByHeader("X-API-Key")),
+		ratelimit.WithHandler(tooManyRequestsHandler), // 429 by default
+	)
+
+	// attach to a route or a route group
+	_ = router.Post("/login", loginHandler, compass.Interceptors(rl))
+
+#### Timeout
+
+`interceptor/timeout` sets a deadline on the request context and responds with
+503 when the handler doesn't finish in time. The responses are buffered like
+`http.TimeoutHandler` so the timeout response can replace them. The routes can
+override the default timeout:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/timeout"
+		...
+	)
+
+	tm, _ := timeout.New(2*time.Second,
+		timeout.WithHandler(gatewayTimeoutHandler), // 503 by default
+	)
+	router, _ := compass.New(compass.WithInterceptors(tm))
+
+	_ = router.Get("/exports/:id", exportHandler,
+		timeout.For(5*time.Minute),
+		timeout.Streaming(),
+	)
+
+`Streaming` routes write their responses directly without buffering. The
+handlers can't be interrupted, so they must stop when the request context is
+cancelled, and the timeout handler is only served when nothing is written
+before the deadline.
+
+#### Compression
+
+`interceptor/compress` compresses the responses with gzip or deflate as
+negotiated with the `Accept-Encoding` header and adds `Vary: Accept-Encoding`.
+Small responses, already compressed content types like images and archives, and
+the responses with a `Content-Encoding` are written as they are:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/compress"
+		...
+	)
+
+	c, _ := compress.New(
+		compress.WithLevel(gzip.BestSpeed),
+		compress.WithMinSize(512),                 // 1024 bytes by default
+		compress.WithSkipTypes("application/pdf"), // in addition to the defaults
+	)
+	router, _ := compass.New(compass.WithInterceptors(c))
+
+	_ = router.Get("/downloads/:file", downloadHandler, compress.Skip())
+
+Flushing a response flushes the compressed data, so streaming handlers keep
+working.
+
+#### ETag
+
+`interceptor/etag` buffers the GET responses to set their `ETag` headers unless
+the handlers set one, and answers `If-None-Match` and `If-Modified-Since` with
+304. With a resolver, the preconditions of the unsafe methods are checked
+against the current representation served by a GET subrequest, so `If-Match`
+and `If-Unmodified-Since` give optimistic concurrency without custom code:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/etag"
+		...
+	)
+
+	e, _ := etag.New()
+	router, _ := compass.New(compass.WithInterceptors(e))
+	_ = etag.WithResolver(router)(e)
+
+	_ = router.Get("/resources/:id", getResourceHandler)
+	_ = router.Put("/resources/:id", putResourceHandler) // 412 on stale If-Match
+	_ = router.Get("/events", eventsHandler, etag.Skip())
+
+The strong tags are generated from the response bodies, so the interceptor must
+run after the interceptors which modify them like `interceptor/compress`,
+otherwise `etag.WithWeak()` option must be used.
+
+The GET subrequests are marked with `interceptor.WithSubrequest`, the rate
+limit, bulkhead, metrics, access log and cache interceptors skip them. The
+preconditions are checked before the handler runs without locking the resource,
+so the handlers which need atomic updates must still compare the versions while
+they update the resources.
+
+#### Cache
+
+`interceptor/cache` caches the GET responses in memory up to the given total
+size and evicts the least recently used ones. The cache keys are built from the
+route pattern, the params, the query, the configured headers and the headers
+listed in the `Vary` response header. The responses are cached for their
+`s-maxage` or `max-age` lifetimes and served stale while they are revalidated in
+the background within their `stale-while-revalidate` periods:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/cache"
+		...
+	)
+
+	c, _ := cache.New(64<<20, // 64MB
+		cache.WithHeaders("X-Tenant-ID"),
+		cache.WithTTL(time.Minute), // for the responses without max-age
+	)
+	router, _ := compass.New(compass.WithInterceptors(c))
+
+	_ = router.Get("/products/:id", productHandler, compass.Name("product"))
+	_ = router.Get("/me", meHandler, cache.Skip())
+
+	// after updating a product
+	c.Purge("product")
+
+The `X-Cache` header tells whether the response is a `HIT`, `MISS` or `STALE`.
+The `private`, `no-store` and `no-cache` responses, the responses with cookies
+and the responses to the requests with credentials which aren't `public` are
+never cached. The `Authorization`, `Cookie` and `X-API-Key` request headers are
+credentials unless they are a part of the cache key, `cache.WithCredentials`
+option adds the other headers like the custom API key headers.
+
+#### Authentication
+
+`interceptor/auth` authenticates the requests with Basic credentials, Bearer
+JSON Web Tokens signed with HS256, RS256 or ES256, or API keys in a header or a
+query param. The verified claims are stored in the request context, and the
+requests without valid credentials are responded with 401:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/auth"
+		...
+	)
+
+	keyFunc, _ := auth.JWKSFile("/etc/api/jwks.json")
+	tokens, _ := auth.JWT(keyFunc,
+		auth.WithIssuer("https://auth.example.com"),
+		auth.WithAudience("api"),
+		auth.WithLeeway(30*time.Second),
+	)
+	keys, _ := auth.APIKey(auth.Keys(apiKeys), auth.FromQuery("api_key"))
+
+	a, _ := auth.New(auth.Any(tokens, keys))
+	router, _ := compass.New(compass.WithInterceptors(a))
+
+	_ = router.Get("/healthz", healthHandler, auth.Public())
+	_ = router.Get("/me", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		claims, _ := auth.FromContext(req.Context())
+		fmt.Fprint(rw, claims.Subject)
+	}))
+
+All routes require authentication unless they are `Public`. With
+`auth.WithOptional()` option, only the `auth.Authenticated()` routes require it.
+
+#### Authorization
+
+The routes declare what the authenticated requests require with the
+`auth.RequireScopes`, `auth.RequireRole` and `auth.Policy` route options. The
+requests which aren't authorized are responded with 403:
+
+	a, _ := auth.New(tokens, auth.WithForbiddenHandler(forbiddenHandler))
+	router, _ := compass.New(compass.WithInterceptors(a))
+
+	_ = router.Post("/posts", createPostHandler, auth.RequireScopes("posts:write"))
+	_ = router.Put("/users/:userID", updateUserHandler,
+		auth.Policy("owner", func(c *auth.Claims, params compass.PathParams) bool {
+			return c.Subject == params["userID"]
+		}),
+	)
+
+	admin := router.Group("/admin", auth.RequireRole("admin"))
+
+The policies of the routes are listed by `auth.PoliciesOf(route)` like
+`[scopes(posts:write)]` for the security audits.
+
+#### Bulkhead
+
+`interceptor/bulkhead` limits the in-flight requests so the expensive routes
+can't starve the others. The requests over the limit wait in an optional
+bounded queue and are rejected with 503 and `Retry-After` when the queue is full
+or the wait times out. An interceptor can be shared by a route group, applied
+to a single route, or partition the requests by a key like the route:
+
+	import (
+		"github.com/mustafaturan/compass/interceptor/bulkhead"
+		...
+	)
+
+	b, _ := bulkhead.New(10,
+		bulkhead.WithQueue(20, 2*time.Second),
+		bulkhead.WithRetryAfter(5*time.Second), // 1s by default
+	)
+	reports := router.Group("/reports", compass.Interceptors(b))
+
+	// in-flight and queued gauges
+	b.InFlight()
+	b.Queued()
+
+`bulkhead.WithKey(bulkhead.KeyByRoute)` option limits each route separately.
+
 */
 package compass
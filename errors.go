@@ -0,0 +1,171 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// HandlerFunc is an http.Handler which returns an error, the returned errors
+// are turned into responses by the ErrorMapper of the route
+type HandlerFunc func(http.ResponseWriter, *http.Request) error
+
+// ErrorMapper writes a response for an error returned by a HandlerFunc
+type ErrorMapper func(rw http.ResponseWriter, req *http.Request, err error)
+
+// HTTPError is an error with an HTTP status code, the default ErrorMapper
+// finds it in the error chain with errors.As
+type HTTPError interface {
+	error
+	StatusCode() int
+}
+
+// StatusError is an HTTPError which wraps an error with a status code
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+// ServeHTTP implements http.Handler interface
+func (fn HandlerFunc) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if err := fn(rw, req); err != nil {
+		mapper, ok := req.Context().Value(ctxErrorMapper).(ErrorMapper)
+		if !ok {
+			mapper = DefaultErrorMapper
+		}
+		mapper(rw, req, err)
+	}
+}
+
+// Error implements error interface
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.Code)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status code
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
+
+// StatusCode returns the HTTP status code for the param errors
+func (e *ParamError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// DefaultErrorMapper writes the status code of the HTTPError in the error
+// chain or 500. The error messages are only exposed for 4xx status codes.
+func DefaultErrorMapper(rw http.ResponseWriter, req *http.Request, err error) {
+	statusCode := ErrorStatusCode(err)
+	message := http.StatusText(statusCode)
+	if statusCode < http.StatusInternalServerError {
+		message = err.Error()
+	}
+	http.Error(rw, message, statusCode)
+}
+
+// ProblemErrorMapper writes an RFC 7807 problem document for the status code
+// of the HTTPError in the error chain or 500, the error messages are only
+// exposed as problem details for 4xx status codes
+func ProblemErrorMapper(rw http.ResponseWriter, req *http.Request, err error) {
+	statusCode := ErrorStatusCode(err)
+	detail := ""
+	if statusCode < http.StatusInternalServerError {
+		detail = err.Error()
+	}
+	chandler.WriteProblem(rw, req, chandler.NewProblem(req, statusCode, detail))
+}
+
+// ErrorStatusCode returns the status code of the HTTPError in the error chain,
+// it returns 500 when the chain has no HTTPError
+func ErrorStatusCode(err error) int {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// WithErrorMapper option sets the router level ErrorMapper for the errors
+// returned by HandlerFunc handlers
+func WithErrorMapper(m ErrorMapper) Option {
+	return func(r *router) error {
+		if m == nil {
+			return errors.New("error mapper can't be nil")
+		}
+		r.errormapper = m
+		return nil
+	}
+}
+
+// MapErrors option sets the ErrorMapper of the route, it overrides the router
+// level ErrorMapper and can be applied to route groups
+func MapErrors(m ErrorMapper) RouteOption {
+	return func(h *chandler.Handler) error {
+		if m == nil {
+			return errors.New("error mapper can't be nil")
+		}
+		return Interceptors(errorMapperInterceptor(m))(h)
+	}
+}
+
+func errorMapperInterceptor(m ErrorMapper) cinterceptor.Interceptor {
+	return cinterceptor.Func(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(rw, req.WithContext(withErrorMapper(req.Context(), m)))
+		})
+	})
+}
+
+func withErrorMapper(ctx context.Context, m ErrorMapper) context.Context {
+	return context.WithValue(ctx, ctxErrorMapper, m)
+}
+
+// Reason returns the reason of the router generated status code from the
+// context, it is available to the handlers set with WithHandler option
+func Reason(ctx context.Context) string {
+	return chandler.Reason(ctx)
+}
+
+// mapError is the default router level ErrorMapper which serves the handlers
+// of the router generated status codes with the error message as the reason,
+// the error message is only exposed for 4xx errors
+func (r *router) mapError(rw http.ResponseWriter, req *http.Request, err error) {
+	var h http.Handler
+	code := ErrorStatusCode(err)
+	switch code {
+	case http.StatusInternalServerError:
+		r.internalservererror.ServeHTTP(rw, req)
+		return
+	case http.StatusNotFound:
+		h = r.notfound
+	default:
+		h = r.handlers[code]
+	}
+
+	switch {
+	case h != nil:
+		if code < 500 {
+			req = req.WithContext(chandler.WithReason(req.Context(), err.Error()))
+		}
+		h.ServeHTTP(rw, req)
+	case r.problem:
+		ProblemErrorMapper(rw, req, err)
+	default:
+		DefaultErrorMapper(rw, req, err)
+	}
+}
@@ -0,0 +1,190 @@
+package compass
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	errNotFound := &StatusError{Code: http.StatusNotFound}
+	handler := func(err error) HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) error {
+			return err
+		}
+	}
+
+	tests := []struct {
+		err        error
+		statusCode int
+		body       string
+	}{
+		{nil, http.StatusOK, ""},
+		{errNotFound, http.StatusNotFound, "Not Found\n"},
+		{
+			fmt.Errorf("loading post: %w", &StatusError{
+				Code: http.StatusConflict,
+				Err:  errors.New("version mismatch"),
+			}),
+			http.StatusConflict,
+			"loading post: version mismatch\n",
+		},
+		{
+			&ParamError{Name: "id", Err: ErrParamNotFound},
+			http.StatusBadRequest,
+			"param \"id\": param not found\n",
+		},
+		{errors.New("db is down"), http.StatusInternalServerError, "Internal Server Error\n"},
+		{
+			&StatusError{Code: http.StatusServiceUnavailable, Err: errors.New("connection refused")},
+			http.StatusServiceUnavailable,
+			"Service Unavailable\n",
+		},
+		{
+			&StatusError{Code: http.StatusNotImplemented, Err: errors.New("not yet")},
+			http.StatusNotImplemented,
+			"Not Implemented\n",
+		},
+	}
+
+	for _, test := range tests {
+		r, _ := New()
+		_ = r.Get("/posts", handler(test.err))
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/posts", nil))
+
+		t.Run("maps errors to responses", func(t *testing.T) {
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("maps errors without router", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/posts", nil)
+		handler(errNotFound).ServeHTTP(rw, req)
+		if rw.Code != http.StatusNotFound {
+			t.Fatalf("want status code 404, but got %d", rw.Code)
+		}
+	})
+}
+
+func TestErrorMappers(t *testing.T) {
+	mapper := func(statusCode int) ErrorMapper {
+		return func(rw http.ResponseWriter, req *http.Request, err error) {
+			rw.WriteHeader(statusCode)
+		}
+	}
+	failing := HandlerFunc(func(rw http.ResponseWriter, req *http.Request) error {
+		return errors.New("failed")
+	})
+
+	r, _ := New(WithErrorMapper(mapper(http.StatusBadGateway)))
+	_ = r.Get("/router", failing)
+	_ = r.Get("/route", failing, MapErrors(mapper(http.StatusTeapot)))
+	g := r.Group("/group", MapErrors(mapper(http.StatusConflict)))
+	_ = g.Get("/", failing)
+	_ = g.Get("/route", failing, MapErrors(mapper(http.StatusGone)))
+
+	tests := []struct {
+		path       string
+		statusCode int
+	}{
+		{"/router", http.StatusBadGateway},
+		{"/route", http.StatusTeapot},
+		{"/group", http.StatusConflict},
+		{"/group/route", http.StatusGone},
+	}
+
+	for _, test := range tests {
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+		t.Run("uses the closest error mapper", func(t *testing.T) {
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d for %s, but got %d",
+					test.statusCode, test.path, rw.Code)
+			}
+		})
+	}
+
+	t.Run("rejects nil error mappers", func(t *testing.T) {
+		if _, err := New(WithErrorMapper(nil)); err == nil {
+			t.Fatalf("nil error mapper must be rejected")
+		}
+		if err := r.Get("/nil", failing, MapErrors(nil)); err == nil {
+			t.Fatalf("nil error mapper must be rejected")
+		}
+	})
+}
+
+func TestStatusError(t *testing.T) {
+	err := &StatusError{Code: http.StatusForbidden}
+	if err.Error() != "Forbidden" || err.Unwrap() != nil {
+		t.Fatalf("status error without error must use the status text")
+	}
+	if ErrorStatusCode(err) != http.StatusForbidden {
+		t.Fatalf("status code must be found in the chain")
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	custom := fakeHandler{"custom"}
+	r, _ := New(WithHandler(500, custom), WithProblemDetails())
+	_ = r.Get("/posts/:id", HandlerFunc(func(rw http.ResponseWriter, req *http.Request) error {
+		params, _ := ParamsFromContext(req.Context())
+		_, err := params.Int("id")
+		return err
+	}))
+	_ = r.Get("/panic", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("failed")
+	}))
+
+	tests := []struct {
+		path        string
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{
+			"/missing",
+			http.StatusNotFound,
+			"application/problem+json; charset=utf-8",
+			`{"type":"about:blank","title":"Not Found","status":404,` +
+				`"instance":"/missing"}` + "\n",
+		},
+		{
+			"/posts/x",
+			http.StatusBadRequest,
+			"application/problem+json; charset=utf-8",
+			`{"type":"about:blank","title":"Bad Request","status":400,` +
+				`"detail":"param \"id\": can't convert \"x\" to int: ` +
+				`invalid syntax",` +
+				`"instance":"/posts/x"}` + "\n",
+		},
+		{"/panic", http.StatusOK, "", "custom"},
+	}
+
+	for _, test := range tests {
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+
+		t.Run("responds with problem documents", func(t *testing.T) {
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if got := rw.Header().Get("Content-Type"); got != test.contentType {
+				t.Fatalf("want content type %q, but got %q", test.contentType, got)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+		})
+	}
+}
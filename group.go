@@ -0,0 +1,119 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"net/http"
+	"strings"
+)
+
+// group is a Router which registers routes to its router under a path prefix
+// with the shared route options
+type group struct {
+	router  *router
+	prefix  string
+	options []RouteOption
+}
+
+// Group returns a Router which registers the routes under the path prefix and
+// applies the given route options before the options of each route
+func (r *router) Group(prefix string, options ...RouteOption) Router {
+	return &group{
+		router:  r,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		options: options,
+	}
+}
+
+// Group returns a nested group, the prefixes and options are inherited
+func (g *group) Group(prefix string, options ...RouteOption) Router {
+	opts := make([]RouteOption, 0, len(g.options)+len(options))
+	opts = append(opts, g.options...)
+	opts = append(opts, options...)
+	return &group{
+		router:  g.router,
+		prefix:  g.prefix + strings.TrimSuffix(prefix, "/"),
+		options: opts,
+	}
+}
+
+// ServeHTTP serves the requests with the router of the group
+func (g *group) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	g.router.ServeHTTP(rw, req)
+}
+
+// Routes returns all registered routes of the router of the group
+func (g *group) Routes() []RouteInfo {
+	return g.router.Routes()
+}
+
+// Disable makes the named route respond with 503 until it is enabled
+func (g *group) Disable(name string) error {
+	return g.router.Disable(name)
+}
+
+// Enable makes the named route serve requests again
+func (g *group) Enable(name string) error {
+	return g.router.Enable(name)
+}
+
+// Get registers handler for GET method
+func (g *group) Get(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodGet, path, handler, options)
+}
+
+// Head registers handler for HEAD method
+func (g *group) Head(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodHead, path, handler, options)
+}
+
+// Post registers handler for POST method
+func (g *group) Post(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodPost, path, handler, options)
+}
+
+// Put registers handler for PUT method
+func (g *group) Put(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodPut, path, handler, options)
+}
+
+// Patch registers handler for PATCH method
+func (g *group) Patch(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodPatch, path, handler, options)
+}
+
+// Delete registers handler for DELETE method
+func (g *group) Delete(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodDelete, path, handler, options)
+}
+
+// Connect registers handler for CONNECT method
+func (g *group) Connect(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodConnect, path, handler, options)
+}
+
+// Options registers handler for OPTIONS method
+func (g *group) Options(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodOptions, path, handler, options)
+}
+
+// Trace registers handler for TRACE method
+func (g *group) Trace(path string, handler http.Handler, options ...RouteOption) error {
+	return g.register(http.MethodTrace, path, handler, options)
+}
+
+func (g *group) register(
+	method, path string,
+	handler http.Handler,
+	options []RouteOption,
+) error {
+	if path == "/" && g.prefix != "" {
+		path = ""
+	}
+	opts := make([]RouteOption, 0, len(g.options)+len(options))
+	opts = append(opts, g.options...)
+	opts = append(opts, options...)
+	return g.router.registerHandler(method, g.prefix+path, handler, opts...)
+}
@@ -0,0 +1,72 @@
+package compass
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	r, _ := New()
+	api := r.Group("/api/", Metadata("group", "api"))
+	v1 := api.Group("/v1", Name("v1"))
+
+	regs := []struct {
+		register func(string, http.Handler, ...RouteOption) error
+		method   string
+	}{
+		{v1.Get, http.MethodGet},
+		{v1.Head, http.MethodHead},
+		{v1.Post, http.MethodPost},
+		{v1.Put, http.MethodPut},
+		{v1.Patch, http.MethodPatch},
+		{v1.Delete, http.MethodDelete},
+		{v1.Connect, http.MethodConnect},
+		{v1.Options, http.MethodOptions},
+		{v1.Trace, http.MethodTrace},
+	}
+	for _, reg := range regs {
+		if err := reg.register("/posts", fakeHandler{"ok"}, Name(reg.method)); err != nil {
+			t.Fatalf("group registration must not fail, got %s", err)
+		}
+	}
+	_ = api.Get("/", fakeHandler{"root"})
+
+	t.Run("registers routes under the prefix", func(t *testing.T) {
+		for _, reg := range regs {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest(reg.method, "http://example.com/api/v1/posts", nil)
+			api.ServeHTTP(rw, req)
+			if rw.Code != http.StatusOK {
+				t.Fatalf("want status code 200 for %s, but got %d", reg.method, rw.Code)
+			}
+		}
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/api", nil))
+		if rw.Body.String() != "root" {
+			t.Fatalf("group root must be registered at the prefix")
+		}
+	})
+
+	t.Run("applies group options before route options", func(t *testing.T) {
+		routes := v1.Routes()
+		if len(routes) != len(regs)+1 {
+			t.Fatalf("want %d routes, got %d", len(regs)+1, len(routes))
+		}
+		for i, reg := range regs {
+			route := routes[i]
+			if route.Pattern != "/api/v1/posts" || route.Name != reg.method ||
+				route.Metadata["group"] != "api" {
+				t.Fatalf("group options must be applied: %+v", route)
+			}
+		}
+	})
+
+	t.Run("does not share options between groups", func(t *testing.T) {
+		other := api.Group("/v2")
+		if err := other.Get("/posts", fakeHandler{"ok"}); err != nil {
+			t.Fatalf("sibling group must not inherit options, got %s", err)
+		}
+	})
+}
@@ -7,14 +7,52 @@ package handler
 import (
 	"errors"
 	"net/http"
+	"sync/atomic"
+
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
 )
 
 // Handler is a http.handler for given matcher
 type Handler struct {
 	HTTPHandler http.Handler
 
+	// Name is an optional unique name of the route
+	Name string
+
+	// Metadata keeps arbitrary route attributes for introspection
+	Metadata map[string]interface{}
+
+	// Interceptors are route level interceptors, they are executed after the
+	// router level interceptors in the order that they are applied
+	Interceptors []cinterceptor.Interceptor
+
+	// Consumes are the accepted request media types, empty accepts all
+	Consumes []string
+
+	// Produces are the response media types, empty produces all
+	Produces []string
+
+	// MaxBodyBytes is the max request body size, 0 is unlimited
+	MaxBodyBytes int64
+
+	// Predicates are the request conditions which must hold to serve the
+	// handler in addition to the method and the path
+	Predicates []Predicate
+
+	path     string
 	segments []string
 	params   map[string]int
+	settings map[interface{}]interface{}
+	disabled int32
+}
+
+// Predicate is a named request condition of a handler
+type Predicate struct {
+	// Name describes the condition for introspection like `header X-Version=2`
+	Name string
+
+	// Match reports whether the request satisfies the condition
+	Match func(req *http.Request) bool
 }
 
 const (
@@ -57,11 +95,33 @@ func New(path string, h http.Handler) (*Handler, error) {
 
 	return &Handler{
 		HTTPHandler: h,
+		Metadata:    make(map[string]interface{}),
+		path:        path,
 		segments:    segments,
 		params:      params,
 	}, nil
 }
 
+// SetSetting stores a route setting of a package, the key must be a value of
+// an unexported type of the package to avoid collisions. Unlike Metadata, the
+// settings aren't exposed for introspection.
+func (h *Handler) SetSetting(key, value interface{}) {
+	if h.settings == nil {
+		h.settings = make(map[interface{}]interface{})
+	}
+	h.settings[key] = value
+}
+
+// Setting returns the route setting of the key
+func (h *Handler) Setting(key interface{}) interface{} {
+	return h.settings[key]
+}
+
+// Settings returns all route settings, the returned map must not be modified
+func (h *Handler) Settings() map[interface{}]interface{} {
+	return h.settings
+}
+
 // Params extracts and return params from the given path segments
 func (h *Handler) Params(segments []string) map[string]string {
 	params := make(map[string]string, len(h.params))
@@ -75,3 +135,33 @@ func (h *Handler) Params(segments []string) map[string]string {
 func (h *Handler) Segments() []string {
 	return h.segments
 }
+
+// Path returns the registered path pattern
+func (h *Handler) Path() string {
+	return h.path
+}
+
+// Disable makes the router respond with 503 for the handler
+func (h *Handler) Disable() {
+	atomic.StoreInt32(&h.disabled, 1)
+}
+
+// Enable makes the router serve the handler again
+func (h *Handler) Enable() {
+	atomic.StoreInt32(&h.disabled, 0)
+}
+
+// Disabled reports whether the handler is disabled
+func (h *Handler) Disabled() bool {
+	return atomic.LoadInt32(&h.disabled) == 1
+}
+
+// Matches reports whether the request satisfies all predicates
+func (h *Handler) Matches(req *http.Request) bool {
+	for _, p := range h.Predicates {
+		if !p.Match(req) {
+			return false
+		}
+	}
+	return true
+}
@@ -74,6 +74,11 @@ func TestNew(t *testing.T) {
 				t.Fatalf("want: %+v, got: %+v", test.params, h.params)
 			}
 		})
+		t.Run("has correct path", func(t *testing.T) {
+			if h.Path() != test.path {
+				t.Fatalf("want: %s, got: %s", test.path, h.Path())
+			}
+		})
 	}
 
 	t.Run("without handler", func(t *testing.T) {
@@ -144,3 +149,17 @@ type testHTTPHandler struct{}
 
 func (h testHTTPHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 }
+
+func TestSettings(t *testing.T) {
+	type key int8
+	h, _ := New("/", http.NotFoundHandler())
+	if h.Setting(key(0)) != nil || h.Settings() != nil {
+		t.Fatalf("settings must be empty by default")
+	}
+
+	h.SetSetting(key(0), "value")
+	h.Metadata["0"] = "metadata"
+	if h.Setting(key(0)) != "value" || h.Setting(0) != nil || len(h.Settings()) != 1 {
+		t.Fatalf("settings must be stored by their keys")
+	}
+}
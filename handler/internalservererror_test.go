@@ -1,48 +1,46 @@
 package handler
 
 import (
-	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 )
 
 func TestServeHTTP(t *testing.T) {
-	internalServerError := InternalServerError{}
 	tests := []struct {
-		handlerFunc http.HandlerFunc
-		statusCode  int
+		requestID string
+		body      string
 	}{
-		{
-			handlerFunc: func(rw http.ResponseWriter, req *http.Request) {
-				defer internalServerError.ServeHTTP(rw, req)
-				_, _ = io.WriteString(rw, "<html><body>Hello World!</body></html>")
-			},
-			statusCode: http.StatusOK,
-		},
-		{
-			handlerFunc: func(rw http.ResponseWriter, req *http.Request) {
-				defer internalServerError.ServeHTTP(rw, req)
-				panic("ohh no!")
-			},
-			statusCode: http.StatusInternalServerError,
-		},
+		{"", "Internal Server Error\n"},
+		{"abc", "Internal Server Error\nrequest id: abc\n"},
 	}
 
 	for _, test := range tests {
-		t.Run("has correct status code", func(t *testing.T) {
-			req := httptest.NewRequest("GET", "http://example.com/foo", nil)
-			rw := httptest.NewRecorder()
-			test.handlerFunc(rw, req)
-			resp := rw.Result()
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		if test.requestID != "" {
+			req = req.WithContext(
+				WithRequestID(req.Context(), test.requestID),
+			)
+		}
+		rw := httptest.NewRecorder()
+		InternalServerError{}.ServeHTTP(rw, req)
+		resp := rw.Result()
 
-			if resp.StatusCode != test.statusCode {
+		t.Run("has correct status code", func(t *testing.T) {
+			if resp.StatusCode != http.StatusInternalServerError {
 				t.Fatalf(
 					"want status code %d, but got %d",
-					test.statusCode,
+					http.StatusInternalServerError,
 					resp.StatusCode,
 				)
 			}
 		})
+		t.Run("has correct body", func(t *testing.T) {
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, body)
+			}
+		})
 	}
 }
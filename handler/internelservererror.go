@@ -9,14 +9,35 @@ import (
 )
 
 // InternalServerError implements http.Handler
-type InternalServerError struct{}
+type InternalServerError struct {
+	// Problem enables content negotiated RFC 7807 problem responses
+	Problem bool
+}
 
 // ServeHTTP implements http handler func for http.Handler interface
 func (h InternalServerError) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if err := recover(); err != nil {
-		http.Error(rw,
-			http.StatusText(http.StatusInternalServerError),
-			http.StatusInternalServerError,
-		)
+	writeError(rw, req, http.StatusInternalServerError, h.Problem)
+}
+
+// writeError writes the reason or the status text with the request id when it
+// exists or the problem document of the status code
+func writeError(
+	rw http.ResponseWriter,
+	req *http.Request,
+	statusCode int,
+	problem bool,
+) {
+	reason := Reason(req.Context())
+	if problem {
+		WriteProblem(rw, req, NewProblem(req, statusCode, reason))
+		return
+	}
+	message := reason
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	if id := RequestID(req.Context()); id != "" {
+		message += "\nrequest id: " + id
 	}
+	http.Error(rw, message, statusCode)
 }
@@ -0,0 +1,20 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+)
+
+// NotFound implements http.Handler
+type NotFound struct {
+	// Problem enables content negotiated RFC 7807 problem responses
+	Problem bool
+}
+
+// ServeHTTP implements http handler func for http.Handler interface
+func (h NotFound) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	writeError(rw, req, http.StatusNotFound, h.Problem)
+}
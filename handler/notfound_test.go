@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotFoundServeHTTP(t *testing.T) {
+	tests := []struct {
+		requestID string
+		body      string
+	}{
+		{"", "Not Found\n"},
+		{"abc", "Not Found\nrequest id: abc\n"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		if test.requestID != "" {
+			req = req.WithContext(
+				WithRequestID(req.Context(), test.requestID),
+			)
+		}
+		rw := httptest.NewRecorder()
+		NotFound{}.ServeHTTP(rw, req)
+		resp := rw.Result()
+
+		t.Run("has correct status code", func(t *testing.T) {
+			if resp.StatusCode != http.StatusNotFound {
+				t.Fatalf("want status code 404, but got %d", resp.StatusCode)
+			}
+		})
+		t.Run("has correct body", func(t *testing.T) {
+			body, _ := ioutil.ReadAll(resp.Body)
+			if string(body) != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/mustafaturan/compass/internal/negotiate"
+)
+
+// Problem is an RFC 7807 problem details document
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+const (
+	// ProblemContentType is the media type of the problem documents
+	ProblemContentType = "application/problem+json"
+
+	// problemType is the problem type of the HTTP status code problems
+	problemType = "about:blank"
+
+	contentTypeJSON = "application/json"
+	contentTypeHTML = "text/html"
+	contentTypeText = "text/plain"
+)
+
+// problemOffers are the media types of the problems in preference order
+var problemOffers = []string{
+	ProblemContentType, contentTypeJSON, contentTypeHTML, contentTypeText,
+}
+
+// NewProblem returns a problem for the status code of the request
+func NewProblem(req *http.Request, statusCode int, detail string) Problem {
+	return Problem{
+		Type:      problemType,
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    detail,
+		Instance:  req.URL.Path,
+		RequestID: RequestID(req.Context()),
+	}
+}
+
+// WriteProblem writes the problem in the format negotiated against the Accept
+// header of the request. The problem is written as problem+json when the
+// client accepts JSON or none of the formats, as HTML for browsers and as
+// plain text otherwise.
+func WriteProblem(rw http.ResponseWriter, req *http.Request, p Problem) {
+	contentType := negotiate.Best(req.Header.Get("Accept"), problemOffers...)
+	if contentType == "" {
+		contentType = ProblemContentType
+	}
+
+	var body string
+	switch contentType {
+	case contentTypeHTML:
+		body = p.html()
+	case contentTypeText:
+		body = p.text()
+	default:
+		b, _ := json.Marshal(p)
+		body = string(b) + "\n"
+	}
+
+	header := rw.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(p.Status)
+	fmt.Fprint(rw, body)
+}
+
+func (p Problem) text() string {
+	var b strings.Builder
+	b.WriteString(p.Title)
+	if p.Detail != "" {
+		b.WriteString("\n" + p.Detail)
+	}
+	if p.RequestID != "" {
+		b.WriteString("\nrequest id: " + p.RequestID)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (p Problem) html() string {
+	var b strings.Builder
+	title := html.EscapeString(p.Title)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><title>%d %s</title></head>"+
+		"<body><h1>%d %s</h1>", p.Status, title, p.Status, title)
+	if p.Detail != "" {
+		fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(p.Detail))
+	}
+	if p.RequestID != "" {
+		fmt.Fprintf(&b, "<p>request id: %s</p>", html.EscapeString(p.RequestID))
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			"",
+			"application/problem+json; charset=utf-8",
+			`{"type":"about:blank","title":"Bad Request","status":400,` +
+				`"detail":"id is \u003cinvalid\u003e","instance":"/posts/x",` +
+				`"request_id":"abc"}` + "\n",
+		},
+		{
+			"application/json",
+			"application/json; charset=utf-8",
+			`{"type":"about:blank","title":"Bad Request","status":400,` +
+				`"detail":"id is \u003cinvalid\u003e","instance":"/posts/x",` +
+				`"request_id":"abc"}` + "\n",
+		},
+		{
+			"text/html,*/*;q=0.8",
+			"text/html; charset=utf-8",
+			"<!DOCTYPE html>\n<html><head><title>400 Bad Request</title>" +
+				"</head><body><h1>400 Bad Request</h1>" +
+				"<p>id is &lt;invalid&gt;</p><p>request id: abc</p>" +
+				"</body></html>\n",
+		},
+		{
+			"text/plain",
+			"text/plain; charset=utf-8",
+			"Bad Request\nid is <invalid>\nrequest id: abc\n",
+		},
+		{
+			"image/png",
+			"application/problem+json; charset=utf-8",
+			`{"type":"about:blank","title":"Bad Request","status":400,` +
+				`"detail":"id is \u003cinvalid\u003e","instance":"/posts/x",` +
+				`"request_id":"abc"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/posts/x", nil)
+		req.Header.Set("Accept", test.accept)
+		req = req.WithContext(WithRequestID(req.Context(), "abc"))
+		rw := httptest.NewRecorder()
+		WriteProblem(rw, req, NewProblem(req, http.StatusBadRequest, "id is <invalid>"))
+
+		t.Run("negotiates "+test.accept, func(t *testing.T) {
+			if rw.Code != http.StatusBadRequest {
+				t.Fatalf("want status code 400, but got %d", rw.Code)
+			}
+			if got := rw.Header().Get("Content-Type"); got != test.contentType {
+				t.Fatalf("want content type %q, but got %q", test.contentType, got)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestProblemHandlers(t *testing.T) {
+	tests := []struct {
+		handler    http.Handler
+		statusCode int
+	}{
+		{NotFound{Problem: true}, http.StatusNotFound},
+		{InternalServerError{Problem: true}, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		rw := httptest.NewRecorder()
+		test.handler.ServeHTTP(rw, req)
+
+		t.Run("writes problem documents", func(t *testing.T) {
+			var p Problem
+			if err := json.Unmarshal(rw.Body.Bytes(), &p); err != nil {
+				t.Fatalf("want problem document, but got %q", rw.Body.String())
+			}
+			want := Problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(test.statusCode),
+				Status:   test.statusCode,
+				Instance: "/foo",
+			}
+			if p != want || rw.Code != test.statusCode {
+				t.Fatalf("want %+v, but got %+v", want, p)
+			}
+		})
+	}
+}
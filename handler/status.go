@@ -0,0 +1,85 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// Status implements http.Handler for the router generated status codes
+type Status struct {
+	// Code is the HTTP status code of the responses
+	Code int
+
+	// Problem enables content negotiated RFC 7807 problem responses
+	Problem bool
+}
+
+type ctxKey int8
+
+// requestIDRecorder records the request ids set to the descendant contexts
+type requestIDRecorder struct {
+	id atomic.Value
+}
+
+const (
+	ctxReason    = ctxKey(0)
+	ctxRequestID = ctxKey(1)
+	ctxRecorder  = ctxKey(2)
+)
+
+// ServeHTTP implements http handler func for http.Handler interface
+func (h Status) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	writeError(rw, req, h.Code, h.Problem)
+}
+
+// WithReason returns a copy of the context with the reason of the router
+// generated status code
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, ctxReason, reason)
+}
+
+// Reason returns the reason of the router generated status code from the
+// context, it is empty when the context has no reason
+func Reason(ctx context.Context) string {
+	reason, _ := ctx.Value(ctxReason).(string)
+	return reason
+}
+
+// WithRequestID returns a copy of the context with the request id, the id is
+// also recorded when the context descends from a RecordRequestID context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if recorder, ok := ctx.Value(ctxRecorder).(*requestIDRecorder); ok {
+		recorder.id.Store(id)
+	}
+	return context.WithValue(ctx, ctxRequestID, id)
+}
+
+// RequestID returns the request id from the context, it is empty when the
+// context has no request id
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxRequestID).(string)
+	return id
+}
+
+// RecordRequestID returns a copy of the context which records the request ids
+// set to its descendants, the router uses it to serve the request ids of the
+// panicking requests
+func RecordRequestID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxRecorder, &requestIDRecorder{})
+}
+
+// RecordedRequestID returns the last request id set to the descendants of the
+// RecordRequestID context, it is empty when no request id is recorded
+func RecordedRequestID(ctx context.Context) string {
+	recorder, ok := ctx.Value(ctxRecorder).(*requestIDRecorder)
+	if !ok {
+		return ""
+	}
+	id, _ := recorder.id.Load().(string)
+	return id
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusServeHTTP(t *testing.T) {
+	tests := []struct {
+		reason string
+		body   string
+	}{
+		{"", "Method Not Allowed\n"},
+		{"method PUT is not allowed", "method PUT is not allowed\n"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("PUT", "http://example.com/foo", nil)
+		req = req.WithContext(WithReason(req.Context(), test.reason))
+		rw := httptest.NewRecorder()
+		Status{Code: http.StatusMethodNotAllowed}.ServeHTTP(rw, req)
+
+		t.Run("writes the reason", func(t *testing.T) {
+			if rw.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("want status code 405, but got %d", rw.Code)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("has no reason by default", func(t *testing.T) {
+		if Reason(context.Background()) != "" {
+			t.Fatalf("reason must be empty")
+		}
+	})
+}
+
+func TestDisable(t *testing.T) {
+	h, _ := New("/foo", http.NotFoundHandler())
+	if h.Disabled() {
+		t.Fatalf("handler must be enabled by default")
+	}
+	h.Disable()
+	if !h.Disabled() {
+		t.Fatalf("handler must be disabled")
+	}
+	h.Enable()
+	if h.Disabled() {
+		t.Fatalf("handler must be enabled")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if RequestID(context.Background()) != "" {
+		t.Fatalf("request id must be empty")
+	}
+	ctx := WithRequestID(context.Background(), "abc")
+	if got := RequestID(ctx); got != "abc" {
+		t.Fatalf("want request id abc, got %q", got)
+	}
+
+	if RecordedRequestID(ctx) != "" {
+		t.Fatalf("recorded request id must be empty without a recorder")
+	}
+	parent := RecordRequestID(context.Background())
+	_ = WithRequestID(parent, "def")
+	if got := RecordedRequestID(parent); got != "def" {
+		t.Fatalf("want recorded request id def, got %q", got)
+	}
+}
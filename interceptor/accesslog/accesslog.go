@@ -0,0 +1,307 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package accesslog provides an interceptor which logs one structured line per
+// request.
+package accesslog
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+	"github.com/mustafaturan/compass/interceptor/requestid"
+)
+
+// AccessLog is an interceptor which logs the served requests
+type AccessLog struct {
+	logger          Logger
+	sampler         func(*http.Request) bool
+	redacted        map[string]struct{}
+	skipped         map[string]struct{}
+	requestIDHeader string
+	clientIPHeader  string
+}
+
+// Option is an access log option
+type Option func(*AccessLog) error
+
+// Entry is an access log record of a single request
+type Entry struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	Route     string
+	Params    map[string]string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	ClientIP  string
+	RequestID string
+	UserAgent string
+}
+
+// Logger logs access log entries
+type Logger interface {
+	Log(e Entry)
+}
+
+// LoggerFunc is an adapter to use ordinary functions as Logger
+type LoggerFunc func(e Entry)
+
+const (
+	// Redacted is the replacement value of the redacted fields
+	Redacted = "[REDACTED]"
+
+	// paramsPrefix is the redaction key prefix of the route params
+	paramsPrefix = "params."
+
+	defaultRequestIDHeader = "X-Request-ID"
+)
+
+// Log implements Logger interface
+func (fn LoggerFunc) Log(e Entry) {
+	fn(e)
+}
+
+// New returns a new access log interceptor which writes JSON lines to the
+// standard output unless another logger is configured
+func New(options ...Option) (*AccessLog, error) {
+	a := &AccessLog{
+		logger:          NewWriterLogger(os.Stdout, JSON),
+		sampler:         func(*http.Request) bool { return true },
+		redacted:        make(map[string]struct{}),
+		skipped:         make(map[string]struct{}),
+		requestIDHeader: defaultRequestIDHeader,
+	}
+
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// WithLogger option sets the logger
+func WithLogger(l Logger) Option {
+	return func(a *AccessLog) error {
+		if l == nil {
+			return errors.New("logger can't be nil")
+		}
+		a.logger = l
+		return nil
+	}
+}
+
+// WithSampler option sets a function which decides whether the request is
+// logged
+func WithSampler(sampler func(*http.Request) bool) Option {
+	return func(a *AccessLog) error {
+		if sampler == nil {
+			return errors.New("sampler can't be nil")
+		}
+		a.sampler = sampler
+		return nil
+	}
+}
+
+// WithSampleRate option logs only the given ratio of the requests randomly,
+// the rate must be in (0, 1] range
+func WithSampleRate(rate float64) Option {
+	return func(a *AccessLog) error {
+		if rate <= 0 || rate > 1 {
+			return errors.New("sample rate must be in (0, 1] range")
+		}
+		a.sampler = func(*http.Request) bool { return rand.Float64() < rate }
+		return nil
+	}
+}
+
+// WithRedaction option replaces the values of the given fields with Redacted.
+// The fields are the log keys like `path`, `client_ip`, `request_id`,
+// `user_agent` and `params.<name>` for the route params, the redacted params
+// are also replaced in the path.
+func WithRedaction(fields ...string) Option {
+	return func(a *AccessLog) error {
+		for _, f := range fields {
+			a.redacted[f] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithSkip option disables logging for the given route patterns or paths
+// like `/healthz`
+func WithSkip(routes ...string) Option {
+	return func(a *AccessLog) error {
+		for _, r := range routes {
+			a.skipped[r] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithRequestIDHeader option sets the header to read the request id from when
+// the requestid interceptor hasn't set one to the context, the default is
+// `X-Request-ID`
+func WithRequestIDHeader(header string) Option {
+	return func(a *AccessLog) error {
+		if header == "" {
+			return errors.New("request id header can't be empty")
+		}
+		a.requestIDHeader = header
+		return nil
+	}
+}
+
+// WithClientIPHeader option reads the client IP from the first address of the
+// given header like `X-Forwarded-For`, it must only be used behind a trusted
+// proxy which sets the header
+func WithClientIPHeader(header string) Option {
+	return func(a *AccessLog) error {
+		if header == "" {
+			return errors.New("client ip header can't be empty")
+		}
+		a.clientIPHeader = header
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (a *AccessLog) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		route := compass.Route(req.Context())
+		if a.isSkipped(route, req) || cinterceptor.IsSubrequest(req.Context()) ||
+			!a.sampler(req) {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		start := time.Now()
+		w := cinterceptor.WrapResponseWriter(rw)
+		defer func() {
+			// the panics are recovered by the router with 500 unless the
+			// header is already written
+			recovered := recover()
+			a.logger.Log(a.entry(req, route, w, start, recovered != nil))
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (a *AccessLog) isSkipped(route compass.RouteInfo, req *http.Request) bool {
+	if _, ok := a.skipped[route.Pattern]; ok && route.Found() {
+		return true
+	}
+	_, ok := a.skipped[req.URL.Path]
+	return ok
+}
+
+func (a *AccessLog) entry(
+	req *http.Request,
+	route compass.RouteInfo,
+	w cinterceptor.ResponseWriter,
+	start time.Time,
+	panicked bool,
+) Entry {
+	status := w.Status()
+	switch {
+	case panicked && !w.Written():
+		status = http.StatusInternalServerError
+	case status == 0:
+		status = http.StatusOK
+	}
+	params, _ := compass.ParamsFromContext(req.Context())
+
+	e := Entry{
+		Time:      start,
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Route:     route.Pattern,
+		Params:    make(map[string]string, len(params)),
+		Status:    status,
+		Bytes:     w.BytesWritten(),
+		Duration:  time.Since(start),
+		ClientIP:  a.clientIP(req),
+		RequestID: a.requestID(req, w),
+		UserAgent: req.UserAgent(),
+	}
+	for k, v := range params {
+		e.Params[k] = v
+	}
+	a.redact(&e)
+	return e
+}
+
+func (a *AccessLog) redact(e *Entry) {
+	params := false
+	for field := range a.redacted {
+		switch field {
+		case "path":
+			e.Path = Redacted
+		case "client_ip":
+			e.ClientIP = Redacted
+		case "request_id":
+			e.RequestID = Redacted
+		case "user_agent":
+			e.UserAgent = Redacted
+		default:
+			name := strings.TrimPrefix(field, paramsPrefix)
+			if _, ok := e.Params[name]; ok && name != field {
+				e.Params[name] = Redacted
+				params = true
+			}
+		}
+	}
+	// the path has the values of the redacted params
+	if params && e.Path != Redacted {
+		e.Path = pathOf(e.Route, e.Params)
+	}
+}
+
+// pathOf builds the path from the route pattern with the param values
+func pathOf(pattern string, params map[string]string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = params[segment[1:]]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (a *AccessLog) requestID(req *http.Request, w http.ResponseWriter) string {
+	if id := requestid.FromContext(req.Context()); id != "" {
+		return id
+	}
+	if id := w.Header().Get(a.requestIDHeader); id != "" {
+		return id
+	}
+	return req.Header.Get(a.requestIDHeader)
+}
+
+func (a *AccessLog) clientIP(req *http.Request) string {
+	if a.clientIPHeader != "" {
+		if v := req.Header.Get(a.clientIPHeader); v != "" {
+			return strings.TrimSpace(strings.Split(v, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,196 @@
+package accesslog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mustafaturan/compass"
+	"github.com/mustafaturan/compass/interceptor/requestid"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		option Option
+		err    string
+	}{
+		{WithLogger(nil), "logger can't be nil"},
+		{WithSampler(nil), "sampler can't be nil"},
+		{WithSampleRate(0), "sample rate must be in (0, 1] range"},
+		{WithSampleRate(1.5), "sample rate must be in (0, 1] range"},
+		{WithRequestIDHeader(""), "request id header can't be empty"},
+		{WithClientIPHeader(""), "client ip header can't be empty"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var entries []Entry
+	logger := LoggerFunc(func(e Entry) { entries = append(entries, e) })
+
+	serve := func(a *AccessLog, target string, header http.Header) {
+		r, _ := compass.New(compass.WithInterceptors(a))
+		_ = r.Get("/users/:id/tokens/:token", http.HandlerFunc(
+			func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("X-Request-ID", "res-id")
+				rw.WriteHeader(http.StatusAccepted)
+				_, _ = rw.Write([]byte("ok"))
+			},
+		))
+		_ = r.Get("/healthz", http.HandlerFunc(
+			func(rw http.ResponseWriter, req *http.Request) {},
+		))
+		req := httptest.NewRequest("GET", "http://example.com"+target, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	t.Run("logs entry fields", func(t *testing.T) {
+		entries = nil
+		a, _ := New(WithLogger(logger))
+		serve(a, "/users/1/tokens/secret", http.Header{
+			"User-Agent": {"test"},
+		})
+		if len(entries) != 1 {
+			t.Fatalf("must log exactly one entry, got %d", len(entries))
+		}
+		e := entries[0]
+		e.Time, e.Duration = e.Time.UTC(), 0
+		want := Entry{
+			Time:      e.Time,
+			Method:    "GET",
+			Path:      "/users/1/tokens/secret",
+			Route:     "/users/:id/tokens/:token",
+			Params:    map[string]string{"id": "1", "token": "secret"},
+			Status:    http.StatusAccepted,
+			Bytes:     2,
+			ClientIP:  "10.0.0.1",
+			RequestID: "res-id",
+			UserAgent: "test",
+		}
+		if !reflect.DeepEqual(want, e) {
+			t.Fatalf("want: %+v, got: %+v", want, e)
+		}
+	})
+
+	t.Run("redacts fields", func(t *testing.T) {
+		entries = nil
+		a, _ := New(
+			WithLogger(logger),
+			WithRedaction("params.token", "path", "client_ip", "user_agent",
+				"request_id", "token"),
+		)
+		serve(a, "/users/1/tokens/secret", nil)
+		e := entries[0]
+		if e.Params["token"] != Redacted || e.Params["id"] != "1" {
+			t.Fatalf("params must be redacted by name: %+v", e.Params)
+		}
+		if e.Path != Redacted || e.ClientIP != Redacted ||
+			e.UserAgent != Redacted || e.RequestID != Redacted {
+			t.Fatalf("fields must be redacted: %+v", e)
+		}
+	})
+
+	t.Run("redacts params in the path", func(t *testing.T) {
+		var buf bytes.Buffer
+		for _, format := range []Format{Logfmt, JSON} {
+			buf.Reset()
+			a, _ := New(
+				WithLogger(NewWriterLogger(&buf, format)),
+				WithRedaction("params.token"),
+			)
+			serve(a, "/users/1/tokens/secret", nil)
+			if strings.Contains(buf.String(), "secret") {
+				t.Fatalf("redacted param must not be logged: %s", buf.String())
+			}
+			if !strings.Contains(buf.String(), "/users/1/tokens/"+Redacted) {
+				t.Fatalf("path must have the redacted param: %s", buf.String())
+			}
+		}
+	})
+
+	t.Run("skips routes and paths", func(t *testing.T) {
+		entries = nil
+		a, _ := New(WithLogger(logger), WithSkip("/healthz", "/missing"))
+		serve(a, "/healthz", nil)
+		serve(a, "/missing", nil)
+		if len(entries) != 0 {
+			t.Fatalf("skipped routes must not be logged: %+v", entries)
+		}
+	})
+
+	t.Run("samples requests", func(t *testing.T) {
+		entries = nil
+		a, _ := New(
+			WithLogger(logger),
+			WithSampler(func(req *http.Request) bool {
+				return req.URL.Path == "/users/2/tokens/x"
+			}),
+		)
+		serve(a, "/users/1/tokens/x", nil)
+		serve(a, "/users/2/tokens/x", nil)
+		if len(entries) != 1 || entries[0].Params["id"] != "2" {
+			t.Fatalf("only sampled requests must be logged: %+v", entries)
+		}
+	})
+
+	t.Run("logs recovered panics as 500", func(t *testing.T) {
+		entries = nil
+		a, _ := New(WithLogger(logger))
+		r, _ := compass.New(compass.WithInterceptors(a))
+		_ = r.Get("/panic", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			panic("ohh no!")
+		}))
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+		if rw.Code != http.StatusInternalServerError {
+			t.Fatalf("want status code 500, but got %d", rw.Code)
+		}
+		if len(entries) != 1 || entries[0].Status != http.StatusInternalServerError {
+			t.Fatalf("panic must be logged with 500: %+v", entries)
+		}
+	})
+
+	t.Run("reads request id from the context", func(t *testing.T) {
+		entries = nil
+		a, _ := New(WithLogger(logger))
+		rid, _ := requestid.New()
+		r, _ := compass.New(compass.WithInterceptors(rid, a))
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-Request-ID", "ctx-id")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if entries[0].RequestID != "ctx-id" {
+			t.Fatalf("request id must be read from the context: %+v", entries[0])
+		}
+	})
+
+	t.Run("reads client ip and request id headers", func(t *testing.T) {
+		entries = nil
+		a, _ := New(
+			WithLogger(logger),
+			WithClientIPHeader("X-Forwarded-For"),
+			WithRequestIDHeader("X-Correlation-ID"),
+		)
+		serve(a, "/missing", http.Header{
+			"X-Forwarded-For":  {"203.0.113.9, 10.0.0.2"},
+			"X-Correlation-Id": {"req-id"},
+		})
+		e := entries[0]
+		if e.ClientIP != "203.0.113.9" || e.RequestID != "req-id" {
+			t.Fatalf("headers must be used: %+v", e)
+		}
+		if e.Route != "" || e.Status != http.StatusNotFound {
+			t.Fatalf("not found requests must be logged without route: %+v", e)
+		}
+	})
+}
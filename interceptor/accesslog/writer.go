@@ -0,0 +1,134 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format is an access log line format
+type Format int
+
+// writerLogger is a Logger which writes formatted lines to an io.Writer
+type writerLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+}
+
+// jsonEntry is the JSON representation of an Entry
+type jsonEntry struct {
+	Time       string            `json:"time"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Route      string            `json:"route"`
+	Params     map[string]string `json:"params"`
+	Status     int               `json:"status"`
+	Bytes      int64             `json:"bytes"`
+	DurationMS float64           `json:"duration_ms"`
+	ClientIP   string            `json:"client_ip"`
+	RequestID  string            `json:"request_id,omitempty"`
+	UserAgent  string            `json:"user_agent,omitempty"`
+}
+
+const (
+	// JSON formats each entry as a JSON object line
+	JSON Format = iota
+
+	// Logfmt formats each entry as `key=value` pairs
+	Logfmt
+)
+
+// NewWriterLogger returns a Logger which writes one line per entry in the
+// given format to the writer, writes are serialized
+func NewWriterLogger(w io.Writer, format Format) Logger {
+	return &writerLogger{w: w, format: format}
+}
+
+// Log implements Logger interface
+func (l *writerLogger) Log(e Entry) {
+	var line []byte
+	if l.format == Logfmt {
+		line = formatLogfmt(e)
+	} else {
+		line = formatJSON(e)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(line)
+}
+
+func formatJSON(e Entry) []byte {
+	line, _ := json.Marshal(jsonEntry{
+		Time:       e.Time.UTC().Format(time.RFC3339Nano),
+		Method:     e.Method,
+		Path:       e.Path,
+		Route:      e.Route,
+		Params:     e.Params,
+		Status:     e.Status,
+		Bytes:      e.Bytes,
+		DurationMS: durationMS(e.Duration),
+		ClientIP:   e.ClientIP,
+		RequestID:  e.RequestID,
+		UserAgent:  e.UserAgent,
+	})
+	return append(line, '\n')
+}
+
+func formatLogfmt(e Entry) []byte {
+	var b bytes.Buffer
+	writePair(&b, "time", e.Time.UTC().Format(time.RFC3339Nano))
+	writePair(&b, "method", e.Method)
+	writePair(&b, "path", e.Path)
+	writePair(&b, "route", e.Route)
+
+	names := make([]string, 0, len(e.Params))
+	for name := range e.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writePair(&b, paramsPrefix+name, e.Params[name])
+	}
+
+	writePair(&b, "status", strconv.Itoa(e.Status))
+	writePair(&b, "bytes", strconv.FormatInt(e.Bytes, 10))
+	writePair(&b, "duration_ms",
+		strconv.FormatFloat(durationMS(e.Duration), 'f', -1, 64))
+	writePair(&b, "client_ip", e.ClientIP)
+	if e.RequestID != "" {
+		writePair(&b, "request_id", e.RequestID)
+	}
+	if e.UserAgent != "" {
+		writePair(&b, "user_agent", e.UserAgent)
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+func writePair(b *bytes.Buffer, key, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(key)
+	b.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		b.WriteString(strconv.Quote(value))
+		return
+	}
+	b.WriteString(value)
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
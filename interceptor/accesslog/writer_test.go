@@ -0,0 +1,59 @@
+package accesslog
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriterLogger(t *testing.T) {
+	e := Entry{
+		Time:      time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		Method:    "GET",
+		Path:      "/posts/1",
+		Route:     "/posts/:id",
+		Params:    map[string]string{"id": "1", "slug": "hello world"},
+		Status:    200,
+		Bytes:     12,
+		Duration:  1500 * time.Microsecond,
+		ClientIP:  "10.0.0.1",
+		RequestID: "abc",
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: JSON,
+			want: `{"time":"2021-02-03T04:05:06Z","method":"GET",` +
+				`"path":"/posts/1","route":"/posts/:id",` +
+				`"params":{"id":"1","slug":"hello world"},"status":200,` +
+				`"bytes":12,"duration_ms":1.5,"client_ip":"10.0.0.1",` +
+				`"request_id":"abc"}` + "\n",
+		},
+		{
+			format: Logfmt,
+			want: `time=2021-02-03T04:05:06Z method=GET path=/posts/1 ` +
+				`route=/posts/:id params.id=1 params.slug="hello world" ` +
+				`status=200 bytes=12 duration_ms=1.5 client_ip=10.0.0.1 ` +
+				`request_id=abc` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		NewWriterLogger(&buf, test.format).Log(e)
+		if buf.String() != test.want {
+			t.Fatalf("want:\n%s\ngot:\n%s", test.want, buf.String())
+		}
+	}
+
+	t.Run("quotes empty logfmt values", func(t *testing.T) {
+		var buf bytes.Buffer
+		NewWriterLogger(&buf, Logfmt).Log(Entry{})
+		if !bytes.Contains(buf.Bytes(), []byte(` route="" `)) {
+			t.Fatalf("empty values must be quoted: %s", buf.String())
+		}
+	})
+}
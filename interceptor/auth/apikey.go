@@ -0,0 +1,114 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+)
+
+// apiKey authenticates the requests with the API keys in a header or a query
+// param
+type apiKey struct {
+	lookup KeyLookup
+	header string
+	query  string
+}
+
+// APIKeyOption is an API key authenticator option
+type APIKeyOption func(*apiKey) error
+
+// KeyLookup returns the claims of the API key, it returns
+// ErrInvalidCredentials for the unknown keys. The returned claims aren't
+// modified, nil claims are treated as invalid credentials.
+type KeyLookup func(key string) (*Claims, error)
+
+const (
+	methodAPIKey = "apikey"
+
+	defaultAPIKeyHeader = "X-API-Key"
+)
+
+// APIKey returns an authenticator which verifies the API keys of the requests
+// with the lookup function, the keys are read from the `X-API-Key` header
+// unless another source is configured
+func APIKey(lookup KeyLookup, options ...APIKeyOption) (Authenticator, error) {
+	if lookup == nil {
+		return nil, errors.New("key lookup can't be nil")
+	}
+	a := &apiKey{lookup: lookup, header: defaultAPIKeyHeader}
+
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// FromHeader option reads the API keys from the header
+func FromHeader(header string) APIKeyOption {
+	return func(a *apiKey) error {
+		if header == "" {
+			return errors.New("header can't be empty")
+		}
+		a.header = header
+		return nil
+	}
+}
+
+// FromQuery option reads the API keys from the query param when the header
+// doesn't have one, the query params may be logged by the proxies so the
+// header should be preferred
+func FromQuery(param string) APIKeyOption {
+	return func(a *apiKey) error {
+		if param == "" {
+			return errors.New("query param can't be empty")
+		}
+		a.query = param
+		return nil
+	}
+}
+
+// Keys returns a KeyLookup of the static keys with their subjects, the keys
+// are looked up by their hashes
+func Keys(keys map[string]string) KeyLookup {
+	subjects := make(map[[sha256.Size]byte]string, len(keys))
+	for key, subject := range keys {
+		subjects[sha256.Sum256([]byte(key))] = subject
+	}
+	return func(key string) (*Claims, error) {
+		subject, ok := subjects[sha256.Sum256([]byte(key))]
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+		return &Claims{Subject: subject}, nil
+	}
+}
+
+// Authenticate implements Authenticator
+func (a *apiKey) Authenticate(req *http.Request) (*Claims, error) {
+	key := req.Header.Get(a.header)
+	if key == "" && a.query != "" {
+		key = req.URL.Query().Get(a.query)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if claims == nil {
+		return nil, ErrInvalidCredentials
+	}
+	// the lookup may return shared claims
+	c := *claims
+	c.Method = methodAPIKey
+	return &c, nil
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	t.Run("options", func(t *testing.T) {
+		tests := []struct {
+			lookup KeyLookup
+			option APIKeyOption
+			err    string
+		}{
+			{nil, FromHeader("X-Key"), "key lookup can't be nil"},
+			{Keys(nil), FromHeader(""), "header can't be empty"},
+			{Keys(nil), FromQuery(""), "query param can't be empty"},
+		}
+		for _, test := range tests {
+			if _, err := APIKey(test.lookup, test.option); err == nil || err.Error() != test.err {
+				t.Fatalf("want err(%s), got err(%v)", test.err, err)
+			}
+		}
+	})
+
+	a, _ := APIKey(Keys(map[string]string{"secret": "client"}), FromHeader("X-Key"), FromQuery("api_key"))
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		err    error
+	}{
+		{"header", "secret", "", nil},
+		{"query", "", "secret", nil},
+		{"header precedes query", "invalid", "secret", ErrInvalidCredentials},
+		{"invalid", "", "invalid", ErrInvalidCredentials},
+		{"no credentials", "", "", ErrNoCredentials},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/?api_key="+test.query, nil)
+			req.Header.Set("X-Key", test.header)
+			claims, err := a.Authenticate(req)
+			if err != test.err {
+				t.Fatalf("want err(%v), got err(%v)", test.err, err)
+			}
+			if err == nil && (claims.Subject != "client" || claims.Method != "apikey") {
+				t.Fatalf("want api key claims of client, got %+v", claims)
+			}
+		})
+	}
+	t.Run("doesn't modify the looked up claims", func(t *testing.T) {
+		shared := &Claims{Subject: "client"}
+		a, _ := APIKey(func(key string) (*Claims, error) { return shared, nil })
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-API-Key", "secret")
+		claims, err := a.Authenticate(req)
+		if err != nil || claims == shared || claims.Method != "apikey" {
+			t.Fatalf("want a copy of the claims, got %+v, err(%v)", claims, err)
+		}
+		if shared.Method != "" {
+			t.Fatalf("looked up claims must not be modified: %+v", shared)
+		}
+	})
+
+	t.Run("nil claims are invalid", func(t *testing.T) {
+		a, _ := APIKey(func(key string) (*Claims, error) { return nil, nil })
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-API-Key", "secret")
+		if _, err := a.Authenticate(req); err != ErrInvalidCredentials {
+			t.Fatalf("want err(%v), got err(%v)", ErrInvalidCredentials, err)
+		}
+	})
+}
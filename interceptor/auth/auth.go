@@ -0,0 +1,265 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package auth provides an interceptor which authenticates the requests with
+// Basic credentials, Bearer JSON Web Tokens or API keys and stores the
+// verified claims in the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+)
+
+// Auth is an interceptor which authenticates the requests, the routes require
+// authentication unless they are Public or the authentication is optional
+type Auth struct {
+	authenticator Authenticator
+	handler       http.Handler
+	forbidden     http.Handler
+	optional      bool
+}
+
+// Option is an auth option
+type Option func(*Auth) error
+
+// Authenticator verifies the credentials of the requests
+type Authenticator interface {
+	// Authenticate returns the claims of the verified credentials, it returns
+	// ErrNoCredentials when the request doesn't have the credentials of the
+	// authenticator
+	Authenticate(req *http.Request) (*Claims, error)
+}
+
+// AuthenticatorFunc is an adapter to use ordinary functions as Authenticator
+type AuthenticatorFunc func(req *http.Request) (*Claims, error)
+
+// Challenger is implemented by the authenticators which respond with a
+// WWW-Authenticate challenge for the unauthenticated requests
+type Challenger interface {
+	Challenge() string
+}
+
+// Claims are the verified claims of the authenticated requests
+type Claims struct {
+	// Method is the authentication method like `basic`, `jwt` or `apikey`
+	Method string
+
+	// Subject is the authenticated user or client
+	Subject string
+
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	Scopes    []string
+	Roles     []string
+
+	// Extra has all claims of the tokens and the custom claims of the other
+	// methods
+	Extra map[string]interface{}
+}
+
+// chain tries the authenticators in order
+type chain []Authenticator
+
+type ctxKey int8
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const (
+	ctxClaims = ctxKey(0)
+	ctxAuth   = ctxKey(1)
+
+	settingRequired = settingKey(0)
+	settingPolicies = settingKey(1)
+)
+
+var (
+	// ErrNoCredentials is returned by the authenticators when the request
+	// doesn't have their credentials
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials is returned by the authenticators when the
+	// credentials don't match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// New returns a new auth interceptor which authenticates the requests with
+// the authenticator and responds with 401 when they fail
+func New(authenticator Authenticator, options ...Option) (*Auth, error) {
+	if authenticator == nil {
+		return nil, errors.New("authenticator can't be nil")
+	}
+	a := &Auth{
+		authenticator: authenticator,
+		handler:       chandler.Status{Code: http.StatusUnauthorized},
+		forbidden:     chandler.Status{Code: http.StatusForbidden},
+	}
+
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// WithHandler option sets the handler of the unauthenticated requests, the
+// default handler responds with 401
+func WithHandler(h http.Handler) Option {
+	return func(a *Auth) error {
+		if h == nil {
+			return errors.New("handler can't be nil")
+		}
+		a.handler = h
+		return nil
+	}
+}
+
+// WithForbiddenHandler option sets the handler of the requests which aren't
+// authorized by the route policies, the default handler responds with 403
+func WithForbiddenHandler(h http.Handler) Option {
+	return func(a *Auth) error {
+		if h == nil {
+			return errors.New("forbidden handler can't be nil")
+		}
+		a.forbidden = h
+		return nil
+	}
+}
+
+// WithOptional option makes the authentication optional for the routes which
+// aren't marked Authenticated, the claims of the valid credentials are still
+// stored in the context
+func WithOptional() Option {
+	return func(a *Auth) error {
+		a.optional = true
+		return nil
+	}
+}
+
+// Any returns an authenticator which tries the authenticators in order until
+// one of them finds its credentials in the request
+func Any(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+// Authenticated route option requires authentication for the route even when
+// the authentication is optional
+func Authenticated() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		h.SetSetting(settingRequired, true)
+		return nil
+	}
+}
+
+// Public route option allows the unauthenticated requests to the route, the
+// claims of the valid credentials are still stored in the context
+func Public() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		h.SetSetting(settingRequired, false)
+		return nil
+	}
+}
+
+// FromContext returns the claims of the authenticated request
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ctxClaims).(*Claims)
+	return claims, ok
+}
+
+// WithClaims returns a copy of the context with the claims, it is useful to
+// test handlers without the interceptor
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, ctxClaims, claims)
+}
+
+// Middleware implements interceptor.Interceptor
+func (a *Auth) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		required, ok := compass.Route(req.Context()).Setting(settingRequired).(bool)
+		if !ok {
+			required = !a.optional
+		}
+
+		req = req.WithContext(context.WithValue(req.Context(), ctxAuth, a))
+		claims, err := a.authenticator.Authenticate(req)
+		switch {
+		case err == nil:
+			h.ServeHTTP(rw, req.WithContext(WithClaims(req.Context(), claims)))
+		case !required:
+			h.ServeHTTP(rw, req)
+		default:
+			a.unauthorized(rw, req, err)
+		}
+	})
+}
+
+// unauthorized serves the handler with the challenges of the authenticator
+func (a *Auth) unauthorized(rw http.ResponseWriter, req *http.Request, err error) {
+	if c, ok := a.authenticator.(Challenger); ok {
+		if challenge := c.Challenge(); challenge != "" {
+			rw.Header().Set("WWW-Authenticate", challenge)
+		}
+	}
+	ctx := chandler.WithReason(req.Context(), err.Error())
+	a.handler.ServeHTTP(rw, req.WithContext(ctx))
+}
+
+// Authenticate implements Authenticator
+func (fn AuthenticatorFunc) Authenticate(req *http.Request) (*Claims, error) {
+	return fn(req)
+}
+
+// Authenticate implements Authenticator with the first authenticator which
+// finds its credentials
+func (authenticators chain) Authenticate(req *http.Request) (*Claims, error) {
+	for _, a := range authenticators {
+		claims, err := a.Authenticate(req)
+		if err != ErrNoCredentials {
+			return claims, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// Challenge implements Challenger with the challenges of the authenticators
+func (authenticators chain) Challenge() string {
+	challenges := make([]string, 0, len(authenticators))
+	for _, a := range authenticators {
+		if c, ok := a.(Challenger); ok && c.Challenge() != "" {
+			challenges = append(challenges, c.Challenge())
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
+
+// HasScope reports whether the claims have the scope
+func (c *Claims) HasScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+// HasRole reports whether the claims have the role
+func (c *Claims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil); err == nil || err.Error() != "authenticator can't be nil" {
+		t.Fatalf("want err(authenticator can't be nil), got err(%v)", err)
+	}
+	if _, err := New(Basic("api", nil), WithHandler(nil)); err == nil || err.Error() != "handler can't be nil" {
+		t.Fatalf("want err(handler can't be nil), got err(%v)", err)
+	}
+	if _, err := New(Basic("api", nil), WithForbiddenHandler(nil)); err == nil || err.Error() != "forbidden handler can't be nil" {
+		t.Fatalf("want err(forbidden handler can't be nil), got err(%v)", err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	keys, _ := APIKey(Keys(map[string]string{"secret": "client"}))
+	a := Any(Basic("api", map[string]string{"user": "pass"}), keys)
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		subject string
+		err     error
+	}{
+		{"basic", http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}, "user", nil},
+		{"api key", http.Header{"X-Api-Key": {"secret"}}, "client", nil},
+		{"invalid basic", http.Header{"Authorization": {"Basic dXNlcjp4"}, "X-Api-Key": {"secret"}}, "", ErrInvalidCredentials},
+		{"no credentials", nil, "", ErrNoCredentials},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header = test.header
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			claims, err := a.Authenticate(req)
+			if err != test.err {
+				t.Fatalf("want err(%v), got err(%v)", test.err, err)
+			}
+			if err == nil && claims.Subject != test.subject {
+				t.Fatalf("want subject %s, got %s", test.subject, claims.Subject)
+			}
+		})
+	}
+
+	t.Run("challenge", func(t *testing.T) {
+		want := `Basic realm="api", charset="UTF-8"`
+		if got := a.(Challenger).Challenge(); got != want {
+			t.Fatalf("want challenge %s, got %s", want, got)
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	authenticator := AuthenticatorFunc(func(req *http.Request) (*Claims, error) {
+		switch req.Header.Get("Authorization") {
+		case "":
+			return nil, ErrNoCredentials
+		case "valid":
+			return &Claims{Subject: "user"}, nil
+		}
+		return nil, errors.New("token is expired")
+	})
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if claims, ok := FromContext(req.Context()); ok {
+			_, _ = rw.Write([]byte(claims.Subject))
+		}
+	})
+
+	required, _ := New(Any(authenticator, Basic("api", nil)))
+	optional, _ := New(authenticator, WithOptional())
+	routers := make(map[string]compass.Router)
+	for name, a := range map[string]*Auth{"required": required, "optional": optional} {
+		r, _ := compass.New(compass.WithInterceptors(a))
+		_ = r.Get("/default", handler)
+		_ = r.Get("/public", handler, Public())
+		_ = r.Get("/authenticated", handler, Authenticated())
+		_ = r.Get("/metadata", handler, compass.Metadata("auth", false))
+		routers[name] = r
+	}
+
+	tests := []struct {
+		router        string
+		path          string
+		authorization string
+		statusCode    int
+		body          string
+	}{
+		{"required", "/default", "valid", 200, "user"},
+		{"required", "/default", "", 401, "no credentials\n"},
+		{"required", "/default", "expired", 401, "token is expired\n"},
+		{"required", "/public", "", 200, ""},
+		{"required", "/public", "valid", 200, "user"},
+		{"required", "/public", "expired", 200, ""},
+		{"required", "/metadata", "", 401, "no credentials\n"},
+		{"optional", "/default", "", 200, ""},
+		{"optional", "/default", "valid", 200, "user"},
+		{"optional", "/authenticated", "", 401, "no credentials\n"},
+		{"optional", "/authenticated", "valid", 200, "user"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.router+test.path+" "+test.authorization, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rw := httptest.NewRecorder()
+			routers[test.router].ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if got := rw.Body.String(); got != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, got)
+			}
+			challenge := rw.Header().Get("WWW-Authenticate")
+			if test.statusCode == 401 && test.router == "required" && challenge == "" {
+				t.Fatalf("want WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	c := &Claims{Scopes: []string{"posts:read"}, Roles: []string{"admin"}}
+	if !c.HasScope("posts:read") || c.HasScope("posts:write") {
+		t.Fatalf("HasScope must check the scopes")
+	}
+	if !c.HasRole("admin") || c.HasRole("editor") {
+		t.Fatalf("HasRole must check the roles")
+	}
+}
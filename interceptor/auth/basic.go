@@ -0,0 +1,53 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+)
+
+// basic authenticates the requests with the Basic credentials
+type basic struct {
+	realm string
+	users map[string][sha256.Size]byte
+}
+
+const methodBasic = "basic"
+
+// Basic returns an authenticator which verifies the Basic credentials of the
+// requests against the user passwords, the passwords are compared in constant
+// time
+func Basic(realm string, users map[string]string) Authenticator {
+	b := &basic{realm: realm, users: make(map[string][sha256.Size]byte, len(users))}
+	for user, password := range users {
+		b.users[user] = sha256.Sum256([]byte(password))
+	}
+	return b
+}
+
+// Authenticate implements Authenticator
+func (b *basic) Authenticate(req *http.Request) (*Claims, error) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	// the hashes have the same length, so the comparison doesn't leak the
+	// length of the password, the unknown users are compared too
+	expected, found := b.users[user]
+	given := sha256.Sum256([]byte(password))
+	if subtle.ConstantTimeCompare(expected[:], given[:]) != 1 || !found {
+		return nil, ErrInvalidCredentials
+	}
+	return &Claims{Method: methodBasic, Subject: user}, nil
+}
+
+// Challenge implements Challenger
+func (b *basic) Challenge() string {
+	return "Basic realm=" + strconv.Quote(b.realm) + `, charset="UTF-8"`
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasic(t *testing.T) {
+	b := Basic("api", map[string]string{"user": "pass"})
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		set      bool
+		err      error
+	}{
+		{"valid", "user", "pass", true, nil},
+		{"invalid password", "user", "pas", true, ErrInvalidCredentials},
+		{"unknown user", "other", "pass", true, ErrInvalidCredentials},
+		{"empty password", "other", "", true, ErrInvalidCredentials},
+		{"no credentials", "", "", false, ErrNoCredentials},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			if test.set {
+				req.SetBasicAuth(test.user, test.password)
+			}
+			claims, err := b.Authenticate(req)
+			if err != test.err {
+				t.Fatalf("want err(%v), got err(%v)", test.err, err)
+			}
+			if err == nil && (claims.Subject != test.user || claims.Method != "basic") {
+				t.Fatalf("want basic claims of %s, got %+v", test.user, claims)
+			}
+		})
+	}
+}
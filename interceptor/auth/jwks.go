@@ -0,0 +1,137 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// jwk is a JSON Web Key of a JWK Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// symmetric keys
+	K string `json:"k"`
+}
+
+// key is a parsed key of a JWK Set
+type key struct {
+	alg   string
+	value interface{}
+}
+
+// JWKSFile returns a KeyFunc of the RSA, P-256 EC and symmetric keys of the
+// JWK Set file, the keys are selected by the `kid` header of the tokens. The
+// `kid` header can be omitted when the set has a single key.
+func JWKSFile(path string) (KeyFunc, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func parseJWKS(data []byte) (KeyFunc, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]key, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		value, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q is invalid: %s", k.Kid, err)
+		}
+		keys[k.Kid] = key{alg: k.Alg, value: value}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwk set doesn't have signing keys")
+	}
+
+	// the only key is used for the tokens without the kid header
+	var single *key
+	for _, k := range keys {
+		if len(keys) == 1 {
+			single = &key{alg: k.alg, value: k.value}
+		}
+	}
+
+	return func(h Header) (interface{}, error) {
+		k, ok := keys[h.Kid]
+		if !ok && h.Kid == "" && single != nil {
+			k, ok = *single, true
+		}
+		if !ok || (k.alg != "" && k.alg != h.Alg) {
+			return nil, errInvalidToken
+		}
+		return k.value, nil
+	}, nil
+}
+
+// parse returns the public key or the secret of the JWK
+func (k jwk) parse() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent is invalid")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("curve %q isn't supported", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, fmt.Errorf("key type %q isn't supported", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("number is invalid")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// jwt authenticates the requests with the Bearer JSON Web Tokens
+type jwt struct {
+	keyFunc  KeyFunc
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// JWTOption is a JWT authenticator option
+type JWTOption func(*jwt) error
+
+// KeyFunc returns the verification key of the token by its header, the keys
+// are []byte for HS256, *rsa.PublicKey for RS256 and *ecdsa.PublicKey for
+// ES256
+type KeyFunc func(h Header) (interface{}, error)
+
+// Header is the JOSE header of a token
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+const (
+	methodJWT = "jwt"
+
+	// HS256 is the HMAC SHA-256 algorithm
+	HS256 = "HS256"
+
+	// RS256 is the RSASSA-PKCS1-v1_5 SHA-256 algorithm
+	RS256 = "RS256"
+
+	// ES256 is the ECDSA P-256 SHA-256 algorithm
+	ES256 = "ES256"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// JWT returns an authenticator which verifies the signatures and the time
+// claims of the Bearer tokens with the keys of the key function
+func JWT(keyFunc KeyFunc, options ...JWTOption) (Authenticator, error) {
+	if keyFunc == nil {
+		return nil, errors.New("key func can't be nil")
+	}
+	j := &jwt{keyFunc: keyFunc, now: time.Now}
+
+	for _, o := range options {
+		if err := o(j); err != nil {
+			return nil, err
+		}
+	}
+
+	return j, nil
+}
+
+// WithIssuer option requires the `iss` claim to be the issuer
+func WithIssuer(issuer string) JWTOption {
+	return func(j *jwt) error {
+		if issuer == "" {
+			return errors.New("issuer can't be empty")
+		}
+		j.issuer = issuer
+		return nil
+	}
+}
+
+// WithAudience option requires the `aud` claim to have the audience
+func WithAudience(audience string) JWTOption {
+	return func(j *jwt) error {
+		if audience == "" {
+			return errors.New("audience can't be empty")
+		}
+		j.audience = audience
+		return nil
+	}
+}
+
+// WithLeeway option tolerates the clock skew while validating the `exp` and
+// `nbf` claims
+func WithLeeway(leeway time.Duration) JWTOption {
+	return func(j *jwt) error {
+		if leeway < 0 {
+			return errors.New("leeway can't be negative")
+		}
+		j.leeway = leeway
+		return nil
+	}
+}
+
+// StaticKey returns a KeyFunc which returns the key for all tokens
+func StaticKey(key interface{}) KeyFunc {
+	return func(Header) (interface{}, error) {
+		return key, nil
+	}
+}
+
+// Authenticate implements Authenticator
+func (j *jwt) Authenticate(req *http.Request) (*Claims, error) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return nil, ErrNoCredentials
+	}
+
+	return j.verify(strings.TrimSpace(authorization[7:]))
+}
+
+// Challenge implements Challenger
+func (j *jwt) Challenge() string {
+	return "Bearer"
+}
+
+// verify verifies the signature of the token and validates its claims
+func (j *jwt) verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidToken
+	}
+
+	var h Header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, errInvalidToken
+	}
+	key, err := j.keyFunc(h)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, errInvalidToken
+	}
+	claims, err := claimsOf(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validate validates the time, issuer and audience claims
+func (j *jwt) validate(c *Claims) error {
+	now := j.now()
+	if !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt.Add(j.leeway)) {
+		return errors.New("token is expired")
+	}
+	if !c.NotBefore.IsZero() && now.Add(j.leeway).Before(c.NotBefore) {
+		return errors.New("token is not valid yet")
+	}
+	if j.issuer != "" && c.Issuer != j.issuer {
+		return errors.New("token issuer is invalid")
+	}
+	if j.audience != "" && !contains(c.Audience, j.audience) {
+		return errors.New("token audience is invalid")
+	}
+	return nil
+}
+
+// verifySignature verifies the signature with the key of the algorithm, the
+// key type must match the algorithm to prevent the algorithm confusion
+func verifySignature(alg string, key interface{}, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	valid := false
+	switch k := key.(type) {
+	case []byte:
+		if alg == HS256 {
+			mac := hmac.New(sha256.New, k)
+			_, _ = mac.Write([]byte(signed))
+			valid = hmac.Equal(sig, mac.Sum(nil))
+		}
+	case *rsa.PublicKey:
+		if alg == RS256 {
+			valid = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == ES256 && k.Curve == elliptic.P256() && len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			valid = ecdsa.Verify(k, digest[:], r, s)
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	if !valid {
+		return errInvalidToken
+	}
+	return nil
+}
+
+// claimsOf returns the registered claims with the scopes from the `scope` or
+// `scp` claims and the roles from the `roles` or `role` claims
+func claimsOf(raw map[string]interface{}) (*Claims, error) {
+	c := &Claims{Method: methodJWT, Extra: raw}
+	var err error
+	c.Subject, _ = raw["sub"].(string)
+	c.Issuer, _ = raw["iss"].(string)
+	if c.Audience, err = stringsOf(raw["aud"]); err != nil {
+		return nil, errors.New("token audience is invalid")
+	}
+	for name, t := range map[string]*time.Time{
+		"exp": &c.ExpiresAt,
+		"nbf": &c.NotBefore,
+		"iat": &c.IssuedAt,
+	} {
+		if *t, err = timeOf(raw[name]); err != nil {
+			return nil, fmt.Errorf("token %s claim is invalid", name)
+		}
+	}
+
+	scopes, ok := raw["scope"].(string)
+	if ok {
+		c.Scopes = strings.Fields(scopes)
+	} else if c.Scopes, err = stringsOf(raw["scp"]); err != nil {
+		return nil, errors.New("token scopes are invalid")
+	}
+	roles := raw["roles"]
+	if roles == nil {
+		roles = raw["role"]
+	}
+	if c.Roles, err = stringsOf(roles); err != nil {
+		return nil, errors.New("token roles are invalid")
+	}
+	return c, nil
+}
+
+// stringsOf returns the string or the strings of the claim value
+func stringsOf(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, errInvalidToken
+			}
+			values[i] = s
+		}
+		return values, nil
+	}
+	return nil, errInvalidToken
+}
+
+// timeOf returns the time of the NumericDate claim value
+func timeOf(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), nil
+	}
+	return time.Time{}, errInvalidToken
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
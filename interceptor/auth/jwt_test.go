@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJWT(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	secret := []byte("secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	keys := map[string]interface{}{
+		"hs": secret,
+		"rs": &rsaKey.PublicKey,
+		"es": &ecKey.PublicKey,
+	}
+	keyFunc := func(h Header) (interface{}, error) {
+		key, ok := keys[h.Kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", h.Kid)
+		}
+		return key, nil
+	}
+
+	t.Run("options", func(t *testing.T) {
+		tests := []struct {
+			keyFunc KeyFunc
+			option  JWTOption
+			err     string
+		}{
+			{nil, WithIssuer("issuer"), "key func can't be nil"},
+			{keyFunc, WithIssuer(""), "issuer can't be empty"},
+			{keyFunc, WithAudience(""), "audience can't be empty"},
+			{keyFunc, WithLeeway(-time.Second), "leeway can't be negative"},
+		}
+		for _, test := range tests {
+			if _, err := JWT(test.keyFunc, test.option); err == nil || err.Error() != test.err {
+				t.Fatalf("want err(%s), got err(%v)", test.err, err)
+			}
+		}
+	})
+
+	a, _ := JWT(keyFunc, WithIssuer("issuer"), WithAudience("api"), WithLeeway(time.Minute))
+	a.(*jwt).now = func() time.Time { return now }
+
+	valid := map[string]interface{}{
+		"sub":   "user",
+		"iss":   "issuer",
+		"aud":   []string{"api", "web"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Unix(),
+		"scope": "posts:read posts:write",
+		"roles": []string{"admin"},
+	}
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := make(map[string]interface{}, len(valid))
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		err           string
+	}{
+		{"HS256", "Bearer " + sign(t, HS256, "hs", valid, secret), ""},
+		{"RS256", "Bearer " + sign(t, RS256, "rs", valid, rsaKey), ""},
+		{"ES256", "bearer " + sign(t, ES256, "es", valid, ecKey), ""},
+		{"no credentials", "Basic dXNlcjpwYXNz", "no credentials"},
+		{"malformed", "Bearer token", "invalid token"},
+		{"unknown key", "Bearer " + sign(t, HS256, "other", valid, secret), `unknown key "other"`},
+		{"invalid signature", "Bearer " + sign(t, HS256, "hs", valid, []byte("other")), "invalid token"},
+		{"algorithm confusion", "Bearer " + sign(t, HS256, "rs", valid, secret), "invalid token"},
+		{"none algorithm", "Bearer " + sign(t, "none", "hs", valid, nil), "invalid token"},
+		{"wrong algorithm", "Bearer " + sign(t, ES256, "hs", valid, ecKey), "invalid token"},
+		{"expired", "Bearer " + sign(t, HS256, "hs", with("exp", now.Add(-time.Minute).Unix()), secret), "token is expired"},
+		{"expired in leeway", "Bearer " + sign(t, HS256, "hs", with("exp", now.Add(-time.Second).Unix()), secret), ""},
+		{"not valid yet", "Bearer " + sign(t, HS256, "hs", with("nbf", now.Add(2*time.Minute).Unix()), secret), "token is not valid yet"},
+		{"invalid exp", "Bearer " + sign(t, HS256, "hs", with("exp", "tomorrow"), secret), "token exp claim is invalid"},
+		{"invalid issuer", "Bearer " + sign(t, HS256, "hs", with("iss", "other"), secret), "token issuer is invalid"},
+		{"invalid audience", "Bearer " + sign(t, HS256, "hs", with("aud", "web"), secret), "token audience is invalid"},
+		{"missing audience", "Bearer " + sign(t, HS256, "hs", with("aud", nil), secret), "token audience is invalid"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("Authorization", test.authorization)
+			claims, err := a.Authenticate(req)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("want err(%s), got err(%v)", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want no error, got %v", err)
+			}
+			if claims.Method != "jwt" || claims.Subject != "user" || claims.Issuer != "issuer" ||
+				!reflect.DeepEqual(claims.Audience, []string{"api", "web"}) ||
+				!reflect.DeepEqual(claims.Scopes, []string{"posts:read", "posts:write"}) ||
+				!reflect.DeepEqual(claims.Roles, []string{"admin"}) ||
+				!claims.NotBefore.Equal(now) || claims.Extra["sub"] != "user" {
+				t.Fatalf("claims are invalid: %+v", claims)
+			}
+		})
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rs", "alg": "RS256", "use": "sig",
+				"n": encode(rsaKey.N.Bytes()),
+				"e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "es", "crv": "P-256",
+				"x": encode(ecKey.X.Bytes()),
+				"y": encode(ecKey.Y.Bytes()),
+			},
+			{"kty": "oct", "kid": "hs", "k": encode([]byte("secret"))},
+			{"kty": "RSA", "kid": "enc", "use": "enc"},
+		},
+	}
+	path := writeJSON(t, set)
+	defer os.Remove(path)
+
+	keyFunc, err := JWKSFile(path)
+	if err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+	a, _ := JWT(keyFunc)
+	claims := map[string]interface{}{"sub": "user"}
+
+	tests := []struct {
+		name  string
+		token string
+		err   bool
+	}{
+		{"RS256", sign(t, RS256, "rs", claims, rsaKey), false},
+		{"ES256", sign(t, ES256, "es", claims, ecKey), false},
+		{"HS256", sign(t, HS256, "hs", claims, []byte("secret")), false},
+		{"alg mismatch", sign(t, HS256, "rs", claims, []byte("secret")), true},
+		{"encryption key", sign(t, RS256, "enc", claims, rsaKey), true},
+		{"without kid", sign(t, RS256, "", claims, rsaKey), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			if _, err := a.Authenticate(req); (err != nil) != test.err {
+				t.Fatalf("want error %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	t.Run("single key without kid", func(t *testing.T) {
+		path := writeJSON(t, map[string]interface{}{
+			"keys": []map[string]string{{"kty": "oct", "kid": "hs", "k": encode([]byte("secret"))}},
+		})
+		defer os.Remove(path)
+		keyFunc, _ := JWKSFile(path)
+		a, _ := JWT(keyFunc)
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Authorization", "Bearer "+sign(t, HS256, "", claims, []byte("secret")))
+		if _, err := a.Authenticate(req); err != nil {
+			t.Fatalf("want no error, got %v", err)
+		}
+	})
+
+	t.Run("invalid sets", func(t *testing.T) {
+		tests := []interface{}{
+			map[string]interface{}{"keys": []map[string]string{}},
+			map[string]interface{}{"keys": []map[string]string{{"kty": "EC", "crv": "P-384"}}},
+			map[string]interface{}{"keys": []map[string]string{{"kty": "RSA", "n": "", "e": "AQAB"}}},
+			map[string]interface{}{"keys": []map[string]string{{"kty": "OKP"}}},
+		}
+		for _, set := range tests {
+			path := writeJSON(t, set)
+			if _, err := JWKSFile(path); err == nil {
+				t.Fatalf("want error for %v", set)
+			}
+			os.Remove(path)
+		}
+		if _, err := JWKSFile(path + ".missing"); err == nil {
+			t.Fatalf("want error for missing file")
+		}
+	})
+}
+
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}, key interface{}) string {
+	header, _ := json.Marshal(Header{Alg: alg, Kid: kid, Typ: "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		_, _ = mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("can't sign: %s", err)
+		}
+		sig = make([]byte, 64)
+		copy(sig[32-len(r.Bytes()):32], r.Bytes())
+		copy(sig[64-len(s.Bytes()):], s.Bytes())
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeJSON(t *testing.T, v interface{}) string {
+	f, err := ioutil.TempFile("", "jwks")
+	if err != nil {
+		t.Fatalf("can't create file: %s", err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		t.Fatalf("can't write file: %s", err)
+	}
+	return f.Name()
+}
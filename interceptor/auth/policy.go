@@ -0,0 +1,110 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// PolicyFunc authorizes the authenticated requests by their claims and the
+// routing params
+type PolicyFunc func(claims *Claims, params compass.PathParams) bool
+
+// RequireScopes route option requires the claims to have all scopes
+func RequireScopes(scopes ...string) compass.RouteOption {
+	return policy("scopes", scopes, func(c *Claims, _ compass.PathParams) bool {
+		for _, s := range scopes {
+			if !c.HasScope(s) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RequireRole route option requires the claims to have any of the roles
+func RequireRole(roles ...string) compass.RouteOption {
+	return policy("role", roles, func(c *Claims, _ compass.PathParams) bool {
+		for _, r := range roles {
+			if c.HasRole(r) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Policy route option requires the named policy function to authorize the
+// requests, the name describes the policy in the route policies
+func Policy(name string, fn PolicyFunc) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		if name == "" {
+			return errors.New("policy name can't be empty")
+		}
+		if fn == nil {
+			return errors.New("policy func can't be nil")
+		}
+		return policy("policy", []string{name}, fn)(h)
+	}
+}
+
+// PoliciesOf returns the policy descriptions of the route like
+// `scopes(posts:write)`, `role(admin)` or `policy(owner)` for the security
+// audits
+func PoliciesOf(route compass.RouteInfo) []string {
+	policies, _ := route.Setting(settingPolicies).([]string)
+	return append([]string(nil), policies...)
+}
+
+// policy adds the route interceptor which enforces the policy function and
+// its description to the route policies
+func policy(kind string, args []string, fn PolicyFunc) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		if len(args) == 0 {
+			return errors.New(kind + " can't be empty")
+		}
+		policies, _ := h.Setting(settingPolicies).([]string)
+		policies = append(policies[:len(policies):len(policies)],
+			kind+"("+strings.Join(args, ",")+")")
+		h.SetSetting(settingPolicies, policies)
+		return compass.Interceptors(authorize(fn))(h)
+	}
+}
+
+// authorize returns the route interceptor of the policy function, the
+// unauthenticated requests are responded by the handlers of the auth
+// interceptor in the context
+func authorize(fn PolicyFunc) cinterceptor.Interceptor {
+	return cinterceptor.Func(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			a, ok := req.Context().Value(ctxAuth).(*Auth)
+			if !ok {
+				a = &Auth{
+					handler:   chandler.Status{Code: http.StatusUnauthorized},
+					forbidden: chandler.Status{Code: http.StatusForbidden},
+				}
+			}
+
+			claims, ok := FromContext(req.Context())
+			if !ok {
+				a.unauthorized(rw, req, ErrNoCredentials)
+				return
+			}
+			params, _ := compass.ParamsFromContext(req.Context())
+			if !fn(claims, params) {
+				ctx := chandler.WithReason(req.Context(), "access is denied")
+				a.forbidden.ServeHTTP(rw, req.WithContext(ctx))
+				return
+			}
+			h.ServeHTTP(rw, req)
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestPolicies(t *testing.T) {
+	users := map[string]*Claims{
+		"admin":  {Subject: "1", Roles: []string{"admin"}, Scopes: []string{"posts:read"}},
+		"writer": {Subject: "2", Scopes: []string{"posts:read", "posts:write"}},
+	}
+	authenticator := AuthenticatorFunc(func(req *http.Request) (*Claims, error) {
+		claims, ok := users[req.Header.Get("Authorization")]
+		if !ok {
+			return nil, ErrNoCredentials
+		}
+		return claims, nil
+	})
+	owner := Policy("owner", func(c *Claims, params compass.PathParams) bool {
+		return c.Subject == params["userID"]
+	})
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	a, _ := New(authenticator, WithForbiddenHandler(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusForbidden)
+			_, _ = rw.Write([]byte(compass.Reason(req.Context())))
+		},
+	)))
+	r, _ := compass.New(compass.WithInterceptors(a))
+	_ = r.Post("/posts", handler, RequireScopes("posts:read", "posts:write"), compass.Name("create_post"))
+	_ = r.Get("/users/:userID", handler, RequireRole("editor", "admin"), owner)
+	_ = r.Get("/public", handler, Public(), RequireRole("admin"))
+	admin := r.Group("/admin", RequireRole("admin"))
+	_ = admin.Delete("/posts/:id", handler)
+	_ = r.Get("/metadata", handler, compass.Metadata("auth.policies", []string{"role(admin)"}))
+
+	tests := []struct {
+		method     string
+		path       string
+		user       string
+		statusCode int
+	}{
+		{"POST", "/posts", "writer", 200},
+		{"POST", "/posts", "admin", 403},
+		{"GET", "/users/1", "admin", 200},
+		{"GET", "/users/2", "admin", 403},
+		{"GET", "/users/2", "writer", 403},
+		{"GET", "/public", "", 401},
+		{"GET", "/public", "admin", 200},
+		{"DELETE", "/admin/posts/1", "admin", 200},
+		{"DELETE", "/admin/posts/1", "writer", 403},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path+" "+test.user, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "http://example.com"+test.path, nil)
+			req.Header.Set("Authorization", test.user)
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Code == http.StatusForbidden && rw.Body.String() != "access is denied" {
+				t.Fatalf("want reason access is denied, but got %q", rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("route info", func(t *testing.T) {
+		want := map[string][]string{
+			"/posts":           {"scopes(posts:read,posts:write)"},
+			"/users/:userID":   {"role(editor,admin)", "policy(owner)"},
+			"/public":          {"role(admin)"},
+			"/admin/posts/:id": {"role(admin)"},
+			"/metadata":        nil,
+		}
+		for _, route := range r.Routes() {
+			if got := PoliciesOf(route); !reflect.DeepEqual(got, want[route.Pattern]) {
+				t.Fatalf("want policies %v of %s, but got %v", want[route.Pattern], route.Pattern, got)
+			}
+		}
+	})
+
+	t.Run("without auth interceptor", func(t *testing.T) {
+		r, _ := compass.New()
+		_ = r.Get("/", handler, RequireRole("admin"))
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		if rw.Code != http.StatusUnauthorized {
+			t.Fatalf("want status code 401, but got %d", rw.Code)
+		}
+
+		req = req.WithContext(WithClaims(req.Context(), users["writer"]))
+		rw = httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		if rw.Code != http.StatusForbidden {
+			t.Fatalf("want status code 403, but got %d", rw.Code)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		r, _ := compass.New()
+		tests := []struct {
+			option compass.RouteOption
+			err    string
+		}{
+			{RequireScopes(), "scopes can't be empty"},
+			{RequireRole(), "role can't be empty"},
+			{Policy("", func(*Claims, compass.PathParams) bool { return true }), "policy name can't be empty"},
+			{Policy("owner", nil), "policy func can't be nil"},
+		}
+		for _, test := range tests {
+			if err := r.Get("/", handler, test.option); err == nil || err.Error() != test.err {
+				t.Fatalf("want err(%s), got err(%v)", test.err, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package interceptor
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// BufferWriter is a http.ResponseWriter which buffers the response until it
+// is written to another http.ResponseWriter
+type BufferWriter struct {
+	header http.Header
+	buf    bytes.Buffer
+	status int
+	wrote  bool
+}
+
+// NewBufferWriter returns a new BufferWriter with 200 status code
+func NewBufferWriter() *BufferWriter {
+	return &BufferWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+// Header implements http.ResponseWriter interface
+func (w *BufferWriter) Header() http.Header {
+	return w.header
+}
+
+// Write buffers the body bytes
+func (w *BufferWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.buf.Write(b)
+}
+
+// WriteHeader buffers the status code once
+func (w *BufferWriter) WriteHeader(statusCode int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	w.status = statusCode
+}
+
+// Status returns the buffered status code
+func (w *BufferWriter) Status() int {
+	return w.status
+}
+
+// Bytes returns the buffered body bytes
+func (w *BufferWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// WriteTo writes the buffered response to the given http.ResponseWriter
+func (w *BufferWriter) WriteTo(rw http.ResponseWriter) {
+	dst := rw.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	rw.WriteHeader(w.status)
+	_, _ = rw.Write(w.buf.Bytes())
+}
@@ -0,0 +1,38 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBufferWriter(t *testing.T) {
+	w := NewBufferWriter()
+	if w.Status() != http.StatusOK {
+		t.Fatalf("want default status 200, got %d", w.Status())
+	}
+
+	w.Header().Set("X-Test", "ok")
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("hello"))
+	if w.Status() != http.StatusCreated || string(w.Bytes()) != "hello" {
+		t.Fatalf("want buffered 201 response, got %d %q", w.Status(), w.Bytes())
+	}
+
+	rec := httptest.NewRecorder()
+	w.WriteTo(rec)
+	if rec.Code != http.StatusCreated || rec.Body.String() != "hello" {
+		t.Fatalf("want written 201 response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Test"); got != "ok" {
+		t.Fatalf("want X-Test header ok, got %q", got)
+	}
+
+	w = NewBufferWriter()
+	_, _ = w.Write([]byte("body"))
+	w.WriteHeader(http.StatusTeapot)
+	if w.Status() != http.StatusOK {
+		t.Fatalf("status must not change after the body is written, got %d", w.Status())
+	}
+}
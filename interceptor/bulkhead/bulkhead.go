@@ -0,0 +1,245 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package bulkhead provides an interceptor which limits the number of the
+// concurrent requests per route, route group or key.
+package bulkhead
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// Bulkhead is an interceptor which limits the in-flight requests of each
+// compartment, the requests over the limit wait in a bounded queue or are
+// rejected with 503
+type Bulkhead struct {
+	mu           sync.Mutex
+	limit        int
+	queue        int
+	wait         time.Duration
+	retryAfter   time.Duration
+	key          KeyFunc
+	handler      http.Handler
+	compartments map[string]*compartment
+	inFlight     int
+	queued       int
+}
+
+// Option is a bulkhead option
+type Option func(*Bulkhead) error
+
+// KeyFunc returns the compartment key of the request
+type KeyFunc func(req *http.Request) string
+
+// compartment is the state of the requests of a key
+type compartment struct {
+	inFlight int
+	waiters  []chan struct{}
+}
+
+// DefaultRetryAfter is the default Retry-After duration of the rejected
+// requests
+const DefaultRetryAfter = time.Second
+
+// New returns a new bulkhead interceptor which allows the limit of in-flight
+// requests, all requests share a single compartment unless a key func is set.
+// A single interceptor can be shared by the routes of a group to limit them
+// together.
+func New(limit int, options ...Option) (*Bulkhead, error) {
+	if limit < 1 {
+		return nil, errors.New("limit must be positive")
+	}
+	b := &Bulkhead{
+		limit:        limit,
+		retryAfter:   DefaultRetryAfter,
+		key:          func(*http.Request) string { return "" },
+		handler:      chandler.Status{Code: http.StatusServiceUnavailable},
+		compartments: make(map[string]*compartment),
+	}
+
+	for _, o := range options {
+		if err := o(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// WithQueue option lets up to size requests per compartment wait for the
+// in-flight requests up to the wait duration before they are rejected
+func WithQueue(size int, wait time.Duration) Option {
+	return func(b *Bulkhead) error {
+		if size < 1 || wait <= 0 {
+			return errors.New("queue size and wait must be positive")
+		}
+		b.queue = size
+		b.wait = wait
+		return nil
+	}
+}
+
+// WithKey option sets the func which returns the compartment keys of the
+// requests, like KeyByRoute
+func WithKey(fn KeyFunc) Option {
+	return func(b *Bulkhead) error {
+		if fn == nil {
+			return errors.New("key func can't be nil")
+		}
+		b.key = fn
+		return nil
+	}
+}
+
+// WithRetryAfter option sets the Retry-After duration of the rejected
+// requests
+func WithRetryAfter(d time.Duration) Option {
+	return func(b *Bulkhead) error {
+		if d <= 0 {
+			return errors.New("retry after must be positive")
+		}
+		b.retryAfter = d
+		return nil
+	}
+}
+
+// WithHandler option sets the handler of the rejected requests, the default
+// handler responds with 503
+func WithHandler(h http.Handler) Option {
+	return func(b *Bulkhead) error {
+		if h == nil {
+			return errors.New("handler can't be nil")
+		}
+		b.handler = h
+		return nil
+	}
+}
+
+// KeyByRoute returns the method and the pattern of the matched route, all
+// requests of a route share the same compartment
+func KeyByRoute(req *http.Request) string {
+	route := compass.Route(req.Context())
+	return route.Method + " " + route.Pattern
+}
+
+// InFlight returns the number of the in-flight requests of all compartments
+func (b *Bulkhead) InFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+// Queued returns the number of the waiting requests of all compartments
+func (b *Bulkhead) Queued() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queued
+}
+
+// Middleware implements interceptor.Interceptor
+func (b *Bulkhead) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if cinterceptor.IsSubrequest(req.Context()) {
+			h.ServeHTTP(rw, req)
+			return
+		}
+		key := b.key(req)
+		if !b.acquire(req, key) {
+			b.reject(rw, req)
+			return
+		}
+		defer b.release(key)
+
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// acquire takes a slot of the compartment or waits in its queue until a slot
+// is handed over, the wait ends with the timeout or the request cancellation
+func (b *Bulkhead) acquire(req *http.Request, key string) bool {
+	b.mu.Lock()
+	c, ok := b.compartments[key]
+	if !ok {
+		c = &compartment{}
+		b.compartments[key] = c
+	}
+	if c.inFlight < b.limit {
+		c.inFlight++
+		b.inFlight++
+		b.mu.Unlock()
+		return true
+	}
+	if len(c.waiters) >= b.queue {
+		b.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	c.waiters = append(c.waiters, ready)
+	b.queued++
+	b.mu.Unlock()
+
+	timer := time.NewTimer(b.wait)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-req.Context().Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, w := range c.waiters {
+		if w == ready {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			b.queued--
+			return false
+		}
+	}
+	// the slot is handed over while the wait is ending
+	return true
+}
+
+// release hands the slot over to the first waiting request or frees it
+func (b *Bulkhead) release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.compartments[key]
+	if len(c.waiters) > 0 {
+		ready := c.waiters[0]
+		c.waiters = c.waiters[1:]
+		b.queued--
+		close(ready)
+		return
+	}
+	c.inFlight--
+	b.inFlight--
+	if c.inFlight == 0 {
+		delete(b.compartments, key)
+	}
+}
+
+// reject serves the handler with the Retry-After header
+func (b *Bulkhead) reject(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Retry-After", seconds(b.retryAfter))
+	ctx := chandler.WithReason(req.Context(), "too many concurrent requests")
+	b.handler.ServeHTTP(rw, req.WithContext(ctx))
+}
+
+// seconds returns the duration in seconds rounded up
+func seconds(d time.Duration) string {
+	s := d / time.Second
+	if d%time.Second > 0 {
+		s++
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
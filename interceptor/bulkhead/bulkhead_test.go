@@ -0,0 +1,213 @@
+package bulkhead
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		limit  int
+		option Option
+		err    string
+	}{
+		{0, WithRetryAfter(time.Second), "limit must be positive"},
+		{1, WithQueue(0, time.Second), "queue size and wait must be positive"},
+		{1, WithQueue(1, 0), "queue size and wait must be positive"},
+		{1, WithKey(nil), "key func can't be nil"},
+		{1, WithRetryAfter(0), "retry after must be positive"},
+		{1, WithHandler(nil), "handler can't be nil"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.limit, test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+// blocking serves the requests until they are released
+type blocking struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlocking() *blocking {
+	return &blocking{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (b *blocking) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	b.started <- struct{}{}
+	<-b.release
+}
+
+func serve(h http.Handler, path string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com"+path, nil))
+	return rw
+}
+
+func eventually(t *testing.T, fn func() bool) {
+	for i := 0; i < 1000; i++ {
+		if fn() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("condition isn't satisfied")
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("rejects over the limit", func(t *testing.T) {
+		b, _ := New(2, WithRetryAfter(1500*time.Millisecond))
+		h := newBlocking()
+		mw := b.Middleware(h)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if rw := serve(mw, "/"); rw.Code != http.StatusOK {
+					t.Errorf("want status code 200, but got %d", rw.Code)
+				}
+			}()
+			<-h.started
+		}
+		if b.InFlight() != 2 {
+			t.Fatalf("want 2 in-flight requests, but got %d", b.InFlight())
+		}
+
+		rw := serve(mw, "/")
+		if rw.Code != http.StatusServiceUnavailable || rw.Header().Get("Retry-After") != "2" {
+			t.Fatalf("want 503 with Retry-After 2, but got %d %q", rw.Code, rw.Header().Get("Retry-After"))
+		}
+
+		close(h.release)
+		wg.Wait()
+		if b.InFlight() != 0 || len(b.compartments) != 0 {
+			t.Fatalf("want no in-flight requests, but got %d", b.InFlight())
+		}
+	})
+
+	t.Run("queues up to the size", func(t *testing.T) {
+		b, _ := New(1, WithQueue(1, time.Minute))
+		h := newBlocking()
+		mw := b.Middleware(h)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if rw := serve(mw, "/"); rw.Code != http.StatusOK {
+					t.Errorf("want status code 200, but got %d", rw.Code)
+				}
+			}()
+		}
+		<-h.started
+		eventually(t, func() bool { return b.Queued() == 1 })
+
+		if rw := serve(mw, "/"); rw.Code != http.StatusServiceUnavailable {
+			t.Fatalf("want status code 503 for the full queue, but got %d", rw.Code)
+		}
+
+		h.release <- struct{}{}
+		<-h.started
+		if b.InFlight() != 1 || b.Queued() != 0 {
+			t.Fatalf("want the queued request in-flight, but got %d in-flight %d queued", b.InFlight(), b.Queued())
+		}
+		h.release <- struct{}{}
+		wg.Wait()
+		if b.InFlight() != 0 {
+			t.Fatalf("want no in-flight requests, but got %d", b.InFlight())
+		}
+	})
+
+	t.Run("rejects after the wait timeout", func(t *testing.T) {
+		b, _ := New(1, WithQueue(1, 10*time.Millisecond))
+		h := newBlocking()
+		mw := b.Middleware(h)
+
+		go serve(mw, "/")
+		<-h.started
+		if rw := serve(mw, "/"); rw.Code != http.StatusServiceUnavailable {
+			t.Fatalf("want status code 503, but got %d", rw.Code)
+		}
+		if b.Queued() != 0 {
+			t.Fatalf("want no queued requests, but got %d", b.Queued())
+		}
+		close(h.release)
+	})
+
+	t.Run("stops waiting on cancellation", func(t *testing.T) {
+		b, _ := New(1, WithQueue(1, time.Minute))
+		h := newBlocking()
+		mw := b.Middleware(h)
+
+		go serve(mw, "/")
+		<-h.started
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "http://example.com/", nil).WithContext(ctx)
+		done := make(chan int)
+		go func() {
+			rw := httptest.NewRecorder()
+			mw.ServeHTTP(rw, req)
+			done <- rw.Code
+		}()
+		eventually(t, func() bool { return b.Queued() == 1 })
+		cancel()
+		if code := <-done; code != http.StatusServiceUnavailable {
+			t.Fatalf("want status code 503, but got %d", code)
+		}
+		close(h.release)
+	})
+}
+
+func TestKeyByRoute(t *testing.T) {
+	b, _ := New(1, WithKey(KeyByRoute))
+	reports, cheap := newBlocking(), newBlocking()
+	r, _ := compass.New(compass.WithInterceptors(b))
+	_ = r.Get("/reports/:id", reports)
+	_ = r.Get("/cheap", cheap)
+
+	go serve(r, "/reports/1")
+	<-reports.started
+	go serve(r, "/cheap")
+	<-cheap.started
+
+	if rw := serve(r, "/reports/2"); rw.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want status code 503, but got %d", rw.Code)
+	}
+	if b.InFlight() != 2 {
+		t.Fatalf("want 2 in-flight requests, but got %d", b.InFlight())
+	}
+	close(reports.release)
+	close(cheap.release)
+}
+
+func TestGroup(t *testing.T) {
+	b, _ := New(1)
+	h := newBlocking()
+	r, _ := compass.New()
+	reports := r.Group("/reports", compass.Interceptors(b))
+	_ = reports.Get("/daily", h)
+	_ = reports.Get("/monthly", h)
+	_ = r.Get("/cheap", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	go serve(r, "/reports/daily")
+	<-h.started
+
+	if rw := serve(r, "/reports/monthly"); rw.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want status code 503 for the group, but got %d", rw.Code)
+	}
+	if rw := serve(r, "/cheap"); rw.Code != http.StatusOK {
+		t.Fatalf("want status code 200 outside the group, but got %d", rw.Code)
+	}
+	close(h.release)
+}
@@ -0,0 +1,420 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package cache provides an interceptor which caches the GET responses in a
+// size bounded in-memory LRU store.
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// Cache is an interceptor which serves the cached responses of the GET
+// requests until they expire by their Cache-Control directives
+type Cache struct {
+	mu           sync.Mutex
+	store        *lru
+	headers      []string
+	credentials  []string
+	ttl          time.Duration
+	revalidating map[string]struct{}
+	now          func() time.Time
+}
+
+// Option is a cache option
+type Option func(*Cache) error
+
+// detachedContext keeps the values of the request context without its
+// cancellation for the background revalidations
+type detachedContext struct {
+	context.Context
+}
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const (
+	// HeaderCache is the response header which tells whether the response is
+	// served from the cache
+	HeaderCache = "X-Cache"
+
+	// Hit is the HeaderCache value of the fresh cached responses
+	Hit = "HIT"
+
+	// Miss is the HeaderCache value of the responses served by the handlers
+	Miss = "MISS"
+
+	// Stale is the HeaderCache value of the stale cached responses served
+	// while they are revalidated
+	Stale = "STALE"
+
+	headerAge          = "Age"
+	headerCacheControl = "Cache-Control"
+	headerVary         = "Vary"
+
+	settingEnabled = settingKey(0)
+)
+
+// New returns a new cache interceptor which stores the responses up to the
+// given total size in bytes and evicts the least recently used ones
+func New(maxBytes int64, options ...Option) (*Cache, error) {
+	if maxBytes <= 0 {
+		return nil, errors.New("max bytes must be positive")
+	}
+	c := &Cache{
+		store:        newLRU(maxBytes),
+		credentials:  []string{"Authorization", "Cookie", "X-Api-Key"},
+		revalidating: make(map[string]struct{}),
+		now:          time.Now,
+	}
+
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// WithHeaders option adds the values of the given request headers to the
+// cache keys, the headers listed by the Vary response header are always added
+func WithHeaders(headers ...string) Option {
+	return func(c *Cache) error {
+		for _, h := range headers {
+			if h == "" {
+				return errors.New("header can't be empty")
+			}
+			c.headers = append(c.headers, http.CanonicalHeaderKey(h))
+		}
+		return nil
+	}
+}
+
+// WithCredentials option adds the request headers which carry credentials,
+// the `Authorization`, `Cookie` and `X-API-Key` headers are credentials by
+// default
+func WithCredentials(headers ...string) Option {
+	return func(c *Cache) error {
+		for _, h := range headers {
+			if h == "" {
+				return errors.New("credentials header can't be empty")
+			}
+			c.credentials = append(c.credentials, http.CanonicalHeaderKey(h))
+		}
+		return nil
+	}
+}
+
+// WithTTL option sets the lifetime of the responses without max-age or
+// s-maxage directives, they aren't cached by default
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) error {
+		if ttl <= 0 {
+			return errors.New("ttl must be positive")
+		}
+		c.ttl = ttl
+		return nil
+	}
+}
+
+// Skip route option disables the caching of the route responses
+func Skip() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		h.SetSetting(settingEnabled, false)
+		return nil
+	}
+}
+
+// Purge removes the cached responses of the named route and returns the
+// number of the removed responses
+func (c *Cache) Purge(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.removeFunc(func(e *entry) bool { return e.route == name })
+}
+
+// PurgePattern removes the cached responses of the route with the pattern
+// and returns the number of the removed responses
+func (c *Cache) PurgePattern(pattern string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.removeFunc(func(e *entry) bool { return e.pattern == pattern })
+}
+
+// Len returns the number of the cached responses
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.ll.Len()
+}
+
+// Size returns the total size of the cached responses in bytes
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.size
+}
+
+// Middleware implements interceptor.Interceptor
+func (c *Cache) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		route := compass.Route(req.Context())
+		if enabled, ok := route.Setting(settingEnabled).(bool); (ok && !enabled) ||
+			req.Method != http.MethodGet || !route.Found() ||
+			cinterceptor.IsSubrequest(req.Context()) {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		base := c.baseKey(req, route)
+		now := c.now()
+
+		c.mu.Lock()
+		e := c.store.lookup(base, req, now)
+		revalidate := false
+		if e != nil && !e.fresh(now) {
+			_, ok := c.revalidating[e.key]
+			revalidate = !ok
+			c.revalidating[e.key] = struct{}{}
+		}
+		c.mu.Unlock()
+
+		switch {
+		case e == nil:
+			w := cinterceptor.NewBufferWriter()
+			h.ServeHTTP(w, req)
+			c.add(req, route, base, w, now)
+			w.Header().Set(HeaderCache, Miss)
+			w.WriteTo(rw)
+		case e.fresh(now):
+			e.writeTo(rw, Hit, now)
+		default:
+			if revalidate {
+				go c.revalidate(h, req, route, base, e.key)
+			}
+			e.writeTo(rw, Stale, now)
+		}
+	})
+}
+
+// revalidate serves the request in the background to replace the stale
+// response, the panics of the handler are dropped with the response
+func (c *Cache) revalidate(
+	h http.Handler,
+	req *http.Request,
+	route compass.RouteInfo,
+	base, key string,
+) {
+	defer func() {
+		_ = recover()
+		c.mu.Lock()
+		delete(c.revalidating, key)
+		c.mu.Unlock()
+	}()
+
+	w := cinterceptor.NewBufferWriter()
+	h.ServeHTTP(w, req.WithContext(detachedContext{req.Context()}))
+	c.add(req, route, base, w, c.now())
+}
+
+// add stores the buffered response when it is cacheable
+func (c *Cache) add(
+	req *http.Request,
+	route compass.RouteInfo,
+	base string,
+	w *cinterceptor.BufferWriter,
+	now time.Time,
+) {
+	header := w.Header()
+	if w.Status() != http.StatusOK || header.Get("Set-Cookie") != "" {
+		return
+	}
+	ttl, swr, ok := c.lifetime(req, header)
+	if !ok {
+		return
+	}
+	vary, ok := varyOf(header)
+	if !ok {
+		return
+	}
+
+	e := &entry{
+		key:     variantKey(base, vary, req),
+		base:    base,
+		vary:    vary,
+		route:   route.Name,
+		pattern: route.Pattern,
+		header:  header.Clone(),
+		body:    append([]byte(nil), w.Bytes()...),
+		stored:  now,
+		expires: now.Add(ttl),
+		stale:   now.Add(ttl + swr),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store.add(e)
+}
+
+// lifetime returns the freshness lifetime and the stale-while-revalidate
+// period of the response by its Cache-Control directives. The responses of
+// the requests with credentials are only cached when they are marked public,
+// the credential headers don't count when they are a part of the cache key.
+func (c *Cache) lifetime(req *http.Request, header http.Header) (time.Duration, time.Duration, bool) {
+	directives := parseCacheControl(header.Get(headerCacheControl))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0, 0, false
+		}
+	}
+
+	_, public := directives["public"]
+	ttl, sharedMaxAge := seconds(directives, "s-maxage")
+	if !sharedMaxAge {
+		var ok bool
+		if ttl, ok = seconds(directives, "max-age"); !ok {
+			ttl = c.ttl
+		}
+	}
+	if ttl <= 0 || (c.hasCredentials(req, header) && !public && !sharedMaxAge) {
+		return 0, 0, false
+	}
+
+	swr, _ := seconds(directives, "stale-while-revalidate")
+	return ttl, swr, true
+}
+
+// hasCredentials reports whether the request has credential headers which
+// aren't a part of the cache key
+func (c *Cache) hasCredentials(req *http.Request, header http.Header) bool {
+	vary, _ := varyOf(header)
+	keys := append(vary, c.headers...)
+	for _, credential := range c.credentials {
+		if req.Header.Get(credential) == "" || contains(keys, credential) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// baseKey returns the key of the request without the Vary headers from the
+// route pattern, the params, the query and the configured headers
+func (c *Cache) baseKey(req *http.Request, route compass.RouteInfo) string {
+	var sb strings.Builder
+	sb.WriteString(route.Pattern)
+
+	params, _ := compass.ParamsFromContext(req.Context())
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString("\n" + name + "=" + params[name])
+	}
+
+	sb.WriteString("\n?" + req.URL.Query().Encode())
+	for _, h := range c.headers {
+		sb.WriteString("\n" + h + ":" + strings.Join(req.Header.Values(h), ","))
+	}
+	return sb.String()
+}
+
+// variantKey adds the values of the Vary headers to the base key
+func variantKey(base string, vary []string, req *http.Request) string {
+	if len(vary) == 0 {
+		return base
+	}
+	var sb strings.Builder
+	sb.WriteString(base)
+	for _, h := range vary {
+		sb.WriteString("\nvary " + h + ":" + strings.Join(req.Header.Values(h), ","))
+	}
+	return sb.String()
+}
+
+// varyOf returns the sorted header names of the Vary header, the responses
+// which vary by `*` aren't cacheable
+func varyOf(header http.Header) ([]string, bool) {
+	var names []string
+	for _, v := range header.Values(headerVary) {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			switch name {
+			case "":
+				continue
+			case "*":
+				return nil, false
+			}
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// parseCacheControl returns the lower cased directives with their values
+func parseCacheControl(v string) map[string]string {
+	directives := make(map[string]string)
+	for _, d := range strings.Split(v, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		name, value := d, ""
+		if i := strings.IndexByte(d, '='); i >= 0 {
+			name, value = d[:i], strings.Trim(d[i+1:], `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return directives
+}
+
+func seconds(directives map[string]string, name string) (time.Duration, bool) {
+	v, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// Deadline implements context.Context without the parent deadline
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done implements context.Context without the parent cancellation
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err implements context.Context without the parent cancellation
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	"github.com/mustafaturan/compass/interceptor/auth"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		maxBytes int64
+		option   Option
+		err      string
+	}{
+		{0, nil, "max bytes must be positive"},
+		{1024, WithHeaders(""), "header can't be empty"},
+		{1024, WithTTL(0), "ttl must be positive"},
+		{1024, WithCredentials(""), "credentials header can't be empty"},
+	}
+	for _, test := range tests {
+		opts := []Option{}
+		if test.option != nil {
+			opts = append(opts, test.option)
+		}
+		if _, err := New(test.maxBytes, opts...); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	c, _ := New(1024, WithTTL(time.Minute))
+	tests := []struct {
+		cacheControl  string
+		authorization bool
+		ttl           time.Duration
+		swr           time.Duration
+		ok            bool
+	}{
+		{"", false, time.Minute, 0, true},
+		{"max-age=10", false, 10 * time.Second, 0, true},
+		{"max-age=10, s-maxage=20", false, 20 * time.Second, 0, true},
+		{"max-age=10, stale-while-revalidate=5", false, 10 * time.Second, 5 * time.Second, true},
+		{`Max-Age="30"`, false, 30 * time.Second, 0, true},
+		{"max-age=0", false, 0, 0, false},
+		{"max-age=10, private", false, 0, 0, false},
+		{"no-store", false, 0, 0, false},
+		{"no-cache", false, 0, 0, false},
+		{"max-age=10", true, 0, 0, false},
+		{"max-age=10, public", true, 10 * time.Second, 0, true},
+		{"s-maxage=10", true, 10 * time.Second, 0, true},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if test.authorization {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		header := http.Header{"Cache-Control": {test.cacheControl}}
+		ttl, swr, ok := c.lifetime(req, header)
+		if ttl != test.ttl || swr != test.swr || ok != test.ok {
+			t.Fatalf("want (%s, %s, %v) for %q, got (%s, %s, %v)",
+				test.ttl, test.swr, test.ok, test.cacheControl, ttl, swr, ok)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	c, _ := New(64*1024, WithHeaders("X-Tenant"))
+	c.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	calls := make(map[string]int)
+	handler := func(cacheControl string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[req.URL.Path]++
+			if cacheControl != "" {
+				rw.Header().Set("Cache-Control", cacheControl)
+			}
+			rw.Header().Set("Vary", "Accept-Language")
+			if req.URL.Query().Get("fail") != "" {
+				rw.WriteHeader(http.StatusInternalServerError)
+			}
+			fmt.Fprintf(rw, "%s %d %s", req.URL.Path, calls[req.URL.Path], req.Header.Get("Accept-Language"))
+		})
+	}
+
+	r, _ := compass.New(compass.WithInterceptors(c))
+	_ = r.Get("/users/:id", handler("max-age=60"), compass.Name("user"))
+	_ = r.Get("/orders/:id", handler("max-age=60, stale-while-revalidate=60"))
+	_ = r.Get("/private", handler("private, max-age=60"))
+	_ = r.Get("/skipped", handler("max-age=60"), Skip())
+
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		after  time.Duration
+		cache  string
+		age    string
+		body   string
+	}{
+		{"miss", "/users/1", nil, 0, Miss, "", "/users/1 1 "},
+		{"hit", "/users/1", nil, time.Second, Hit, "1", "/users/1 1 "},
+		{"other params", "/users/2", nil, 0, Miss, "", "/users/2 1 "},
+		{"other query", "/users/1?expand=true", nil, 0, Miss, "", "/users/1 2 "},
+		{"other header", "/users/1", http.Header{"X-Tenant": {"a"}}, 0, Miss, "", "/users/1 3 "},
+		{"vary", "/users/1", http.Header{"Accept-Language": {"tr"}}, 0, Miss, "", "/users/1 4 tr"},
+		{"vary hit", "/users/1", http.Header{"Accept-Language": {"tr"}}, 0, Hit, "0", "/users/1 4 tr"},
+		{"expired", "/users/1", nil, time.Minute, Miss, "", "/users/1 5 "},
+		{"error", "/users/1?fail=1", nil, 0, Miss, "", "/users/1 6 "},
+		{"error not cached", "/users/1?fail=1", nil, 0, Miss, "", "/users/1 7 "},
+		{"private", "/private", nil, 0, Miss, "", "/private 1 "},
+		{"private not cached", "/private", nil, 0, Miss, "", "/private 2 "},
+		{"skipped", "/skipped", nil, 0, "", "", "/skipped 1 "},
+		{"skipped not cached", "/skipped", nil, 0, "", "", "/skipped 2 "},
+		{"swr miss", "/orders/1", nil, 0, Miss, "", "/orders/1 1 "},
+		{"swr stale", "/orders/1", nil, 90 * time.Second, Stale, "90", "/orders/1 1 "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mu.Lock()
+			now = now.Add(test.after)
+			mu.Unlock()
+			req := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if got := rw.Header().Get(HeaderCache); got != test.cache {
+				t.Fatalf("want %s %q, but got %q", HeaderCache, test.cache, got)
+			}
+			if got := rw.Header().Get("Age"); got != test.age {
+				t.Fatalf("want Age %q, but got %q", test.age, got)
+			}
+			if got := rw.Body.String(); got != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, got)
+			}
+		})
+	}
+
+	t.Run("revalidates stale responses in background", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			n := calls["/orders/1"]
+			mu.Unlock()
+			if n == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		req := httptest.NewRequest("GET", "http://example.com/orders/1", nil)
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		if got := rw.Header().Get(HeaderCache); got != Hit {
+			t.Fatalf("want %s %s, but got %s", HeaderCache, Hit, got)
+		}
+		if got := rw.Body.String(); got != "/orders/1 2 " {
+			t.Fatalf("want revalidated body, but got %q", got)
+		}
+	})
+
+	t.Run("purge by name", func(t *testing.T) {
+		if n := c.Purge("user"); n != 5 {
+			t.Fatalf("want 5 purged responses, but got %d", n)
+		}
+	})
+
+	t.Run("purge by pattern", func(t *testing.T) {
+		if n := c.PurgePattern("/orders/:id"); n != 1 {
+			t.Fatalf("want 1 purged response, but got %d", n)
+		}
+		if c.Len() != 0 || c.Size() != 0 {
+			t.Fatalf("want empty cache, but got %d responses of %d bytes", c.Len(), c.Size())
+		}
+	})
+}
+
+func TestCookies(t *testing.T) {
+	me := func(cacheControl string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if cacheControl != "" {
+				rw.Header().Set("Cache-Control", cacheControl)
+			}
+			cookie, _ := req.Cookie("session")
+			_, _ = rw.Write([]byte(cookie.Value))
+		})
+	}
+
+	tests := []struct {
+		name         string
+		options      []Option
+		cacheControl string
+		cache        string
+		body         string
+	}{
+		{"not cached by default ttl", []Option{WithTTL(time.Minute)}, "", Miss, "bob"},
+		{"not cached by max-age", nil, "max-age=60", Miss, "bob"},
+		{"cached when public", nil, "public, max-age=60", Hit, "alice"},
+		{"cached by cookie key", []Option{WithTTL(time.Minute), WithHeaders("Cookie")}, "", Miss, "bob"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := New(1024, test.options...)
+			r, _ := compass.New(compass.WithInterceptors(c))
+			_ = r.Get("/me", me(test.cacheControl))
+
+			for _, user := range []string{"alice", "bob"} {
+				req := httptest.NewRequest("GET", "http://example.com/me", nil)
+				req.AddCookie(&http.Cookie{Name: "session", Value: user})
+				rw := httptest.NewRecorder()
+				r.ServeHTTP(rw, req)
+				if user == "alice" {
+					continue
+				}
+				if got := rw.Header().Get(HeaderCache); got != test.cache {
+					t.Fatalf("want %s %s, but got %s", HeaderCache, test.cache, got)
+				}
+				if got := rw.Body.String(); got != test.body {
+					t.Fatalf("want body %s, but got %s", test.body, got)
+				}
+			}
+			if test.name == "cached by cookie key" && c.Len() != 2 {
+				t.Fatalf("want a response per cookie, but got %d", c.Len())
+			}
+		})
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	lookup := auth.Keys(map[string]string{"secret": "alice"})
+	hello := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		claims, _ := auth.FromContext(req.Context())
+		_, _ = rw.Write([]byte("hello " + claims.Subject))
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		option Option
+	}{
+		{"api key header", "X-API-Key", nil},
+		{"configured header", "X-Token", WithCredentials("X-Token")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []Option{WithTTL(time.Minute)}
+			if test.option != nil {
+				opts = append(opts, test.option)
+			}
+			c, _ := New(1024, opts...)
+			apiKey, _ := auth.APIKey(lookup, auth.FromHeader(test.header))
+			a, _ := auth.New(apiKey)
+			r, _ := compass.New(compass.WithInterceptors(c))
+			_ = r.Get("/me", hello, compass.Interceptors(a))
+
+			req := httptest.NewRequest("GET", "http://example.com/me", nil)
+			req.Header.Set(test.header, "secret")
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+			if rw.Code != http.StatusOK || rw.Body.String() != "hello alice" {
+				t.Fatalf("want 200 hello alice, but got %d %q", rw.Code, rw.Body.String())
+			}
+
+			rw = httptest.NewRecorder()
+			r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/me", nil))
+			if rw.Code != http.StatusUnauthorized || rw.Header().Get(HeaderCache) == Hit {
+				t.Fatalf("want 401 without the cached response, but got %d %q",
+					rw.Code, rw.Body.String())
+			}
+			if c.Len() != 0 {
+				t.Fatalf("responses of the authenticated requests must not be cached")
+			}
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	c, _ := New(1024, WithTTL(time.Minute))
+	r, _ := compass.New(compass.WithInterceptors(c))
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	_ = r.Get("/skipped", ok, Skip())
+	_ = r.Get("/metadata", ok, compass.Metadata("cache", false))
+
+	tests := map[string]string{"/skipped": "", "/metadata": Hit}
+	for path, want := range tests {
+		t.Run(path, func(t *testing.T) {
+			var rw *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				rw = httptest.NewRecorder()
+				r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com"+path, nil))
+			}
+			if got := rw.Header().Get(HeaderCache); got != want {
+				t.Fatalf("want %s %q, but got %q", HeaderCache, want, got)
+			}
+		})
+	}
+}
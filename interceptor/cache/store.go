@@ -0,0 +1,164 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// lru is a size bounded store which evicts the least recently used entries,
+// it isn't safe for concurrent use
+type lru struct {
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+	bases    map[string]*variants
+}
+
+// variants are the Vary header names of the entries of a base key
+type variants struct {
+	vary  []string
+	count int
+}
+
+// entry is a cached response
+type entry struct {
+	key     string
+	base    string
+	vary    []string
+	route   string
+	pattern string
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+	stale   time.Time
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		bases:    make(map[string]*variants),
+	}
+}
+
+// lookup returns the entry of the request which isn't stale beyond its
+// stale-while-revalidate period, the expired entries are removed
+func (s *lru) lookup(base string, req *http.Request, now time.Time) *entry {
+	v, ok := s.bases[base]
+	if !ok {
+		return nil
+	}
+	el, ok := s.items[variantKey(base, v.vary, req)]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	if !now.Before(e.stale) {
+		s.remove(el)
+		return nil
+	}
+	s.ll.MoveToFront(el)
+	return e
+}
+
+// add stores the entry unless it is larger than the store, the least recently
+// used entries are evicted until the entry fits
+func (s *lru) add(e *entry) {
+	if e.size() > s.maxBytes {
+		return
+	}
+	if el, ok := s.items[e.key]; ok {
+		s.remove(el)
+	}
+	// the variants of a base key are replaced when the Vary headers change
+	if v, ok := s.bases[e.base]; ok && !equal(v.vary, e.vary) {
+		s.removeFunc(func(old *entry) bool { return old.base == e.base })
+	}
+
+	for s.size+e.size() > s.maxBytes {
+		s.remove(s.ll.Back())
+	}
+	s.items[e.key] = s.ll.PushFront(e)
+	s.size += e.size()
+	v, ok := s.bases[e.base]
+	if !ok {
+		v = &variants{vary: e.vary}
+		s.bases[e.base] = v
+	}
+	v.count++
+}
+
+// removeFunc removes the entries which satisfy fn and returns their count
+func (s *lru) removeFunc(fn func(*entry) bool) int {
+	n := 0
+	for el := s.ll.Front(); el != nil; {
+		next := el.Next()
+		if fn(el.Value.(*entry)) {
+			s.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+func (s *lru) remove(el *list.Element) {
+	e := s.ll.Remove(el).(*entry)
+	delete(s.items, e.key)
+	s.size -= e.size()
+	if v := s.bases[e.base]; v != nil {
+		v.count--
+		if v.count == 0 {
+			delete(s.bases, e.base)
+		}
+	}
+}
+
+// fresh reports whether the entry hasn't expired yet
+func (e *entry) fresh(now time.Time) bool {
+	return now.Before(e.expires)
+}
+
+// size returns the approximate memory size of the entry in bytes
+func (e *entry) size() int64 {
+	n := len(e.key) + len(e.body)
+	for k, values := range e.header {
+		for _, v := range values {
+			n += len(k) + len(v)
+		}
+	}
+	return int64(n)
+}
+
+// writeTo writes the cached response with its age and the cache status
+func (e *entry) writeTo(rw http.ResponseWriter, status string, now time.Time) {
+	dst := rw.Header()
+	for k, v := range e.header {
+		dst[k] = append([]string(nil), v...)
+	}
+	dst.Set(headerAge, strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	dst.Set(HeaderCache, status)
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(e.body)
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	newEntry := func(key string, size int) *entry {
+		return &entry{
+			key:     key,
+			base:    key,
+			header:  make(http.Header),
+			body:    []byte(strings.Repeat("x", size-len(key))),
+			stored:  now,
+			expires: now.Add(time.Minute),
+			stale:   now.Add(2 * time.Minute),
+		}
+	}
+
+	s := newLRU(30)
+	s.add(newEntry("a", 10))
+	s.add(newEntry("b", 10))
+	s.add(newEntry("c", 10))
+
+	t.Run("evicts the least recently used", func(t *testing.T) {
+		if s.lookup("a", req, now) == nil {
+			t.Fatalf("want entry a")
+		}
+		s.add(newEntry("d", 10))
+		if s.lookup("b", req, now) != nil {
+			t.Fatalf("entry b must be evicted")
+		}
+		if s.size != 30 || s.ll.Len() != 3 {
+			t.Fatalf("want 3 entries of 30 bytes, but got %d of %d bytes", s.ll.Len(), s.size)
+		}
+	})
+
+	t.Run("skips entries larger than the store", func(t *testing.T) {
+		s.add(newEntry("e", 31))
+		if s.lookup("e", req, now) != nil || s.ll.Len() != 3 {
+			t.Fatalf("large entry must not be stored")
+		}
+	})
+
+	t.Run("replaces entries", func(t *testing.T) {
+		s.add(newEntry("a", 20))
+		if s.size != 30 || s.ll.Len() != 2 {
+			t.Fatalf("want 2 entries of 30 bytes, but got %d of %d bytes", s.ll.Len(), s.size)
+		}
+	})
+
+	t.Run("removes stale entries", func(t *testing.T) {
+		if s.lookup("a", req, now.Add(90*time.Second)) == nil {
+			t.Fatalf("entry in stale period must be found")
+		}
+		if s.lookup("a", req, now.Add(2*time.Minute)) != nil {
+			t.Fatalf("stale entry must be removed")
+		}
+		if s.ll.Len() != 1 || len(s.bases) != 1 {
+			t.Fatalf("want 1 entry, but got %d", s.ll.Len())
+		}
+	})
+
+	t.Run("replaces variants when vary changes", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Accept-Language", "tr")
+		e := newEntry("d", 5)
+		e.vary = []string{"Accept-Language"}
+		e.key = variantKey("d", e.vary, req)
+		s.add(e)
+		if s.lookup("d", req, now) != e || s.ll.Len() != 1 {
+			t.Fatalf("want only the variant entry")
+		}
+	})
+}
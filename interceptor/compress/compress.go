@@ -0,0 +1,232 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package compress provides an interceptor which compresses the responses with
+// gzip or deflate encodings negotiated by the Accept-Encoding header.
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+)
+
+// Compress is an interceptor which compresses the responses
+type Compress struct {
+	level     int
+	minSize   int
+	skipTypes map[string]struct{}
+	pools     map[string]*sync.Pool
+}
+
+// Option is a compress option
+type Option func(*Compress) error
+
+// compressor is a pooled gzip or deflate writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const (
+	// DefaultMinSize is the default min response size to compress in bytes
+	DefaultMinSize = 1024
+
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
+
+	headerAcceptEncoding  = "Accept-Encoding"
+	headerContentEncoding = "Content-Encoding"
+	headerContentLength   = "Content-Length"
+	headerContentType     = "Content-Type"
+	headerVary            = "Vary"
+
+	settingEnabled = settingKey(0)
+)
+
+// DefaultSkipTypes are the already compressed content types which aren't
+// compressed again, `image/*`, `video/*` and `audio/*` types except SVG are
+// skipped too
+var DefaultSkipTypes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/zstd",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"font/woff",
+	"font/woff2",
+}
+
+// encodings are the supported encodings in the preference order
+var encodings = []string{encodingGzip, encodingDeflate}
+
+// New returns a new compress interceptor
+func New(options ...Option) (*Compress, error) {
+	c := &Compress{
+		level:     gzip.DefaultCompression,
+		minSize:   DefaultMinSize,
+		skipTypes: make(map[string]struct{}),
+	}
+	for _, t := range DefaultSkipTypes {
+		c.skipTypes[t] = struct{}{}
+	}
+
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	c.pools = map[string]*sync.Pool{
+		encodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(ioutil.Discard, c.level)
+			return w
+		}},
+		encodingDeflate: {New: func() interface{} {
+			w, _ := flate.NewWriter(ioutil.Discard, c.level)
+			return w
+		}},
+	}
+	return c, nil
+}
+
+// WithLevel option sets the compression level, see compress/flate package for
+// the levels
+func WithLevel(level int) Option {
+	return func(c *Compress) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return errors.New("compression level is invalid")
+		}
+		c.level = level
+		return nil
+	}
+}
+
+// WithMinSize option sets the min response size to compress, the smaller
+// responses are written as they are
+func WithMinSize(size int) Option {
+	return func(c *Compress) error {
+		if size < 0 {
+			return errors.New("min size can't be negative")
+		}
+		c.minSize = size
+		return nil
+	}
+}
+
+// WithSkipTypes option adds content types which aren't compressed
+func WithSkipTypes(contentTypes ...string) Option {
+	return func(c *Compress) error {
+		for _, t := range contentTypes {
+			c.skipTypes[strings.ToLower(t)] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// Skip route option disables the compression of the route responses
+func Skip() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		h.SetSetting(settingEnabled, false)
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (c *Compress) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if enabled, ok := compass.Route(req.Context()).Setting(settingEnabled).(bool); ok && !enabled {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		rw.Header().Add(headerVary, headerAcceptEncoding)
+		encoding := negotiate(req.Header.Get(headerAcceptEncoding))
+		if encoding == "" {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		w := newWriter(c, rw, encoding)
+		defer w.close()
+		h.ServeHTTP(w.wrap(), req)
+	})
+}
+
+func (c *Compress) isCompressible(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range []string{"image/", "video/", "audio/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	_, skip := c.skipTypes[mediaType]
+	return !skip
+}
+
+func (c *Compress) get(encoding string, w io.Writer) compressor {
+	cw := c.pools[encoding].Get().(compressor)
+	cw.Reset(w)
+	return cw
+}
+
+func (c *Compress) put(encoding string, cw compressor) {
+	c.pools[encoding].Put(cw)
+}
+
+// negotiate returns the supported encoding with the highest quality value in
+// the Accept-Encoding header, the earlier supported encodings win the ties
+func negotiate(header string) string {
+	if header == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	if q, ok := qualities[encodingIdentity]; ok && q > bestQ {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,227 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		option Option
+		err    string
+	}{
+		{WithLevel(10), "compression level is invalid"},
+		{WithMinSize(-1), "min size can't be negative"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"gzip":                     "gzip",
+		"deflate, gzip":            "gzip",
+		"gzip;q=0.5, deflate":      "deflate",
+		"br":                       "",
+		"*":                        "gzip",
+		"gzip;q=0, *;q=0.1":        "deflate",
+		"identity, gzip;q=0.5":     "",
+		"GZIP;q=0.8, identity;q=1": "",
+	}
+	for header, want := range tests {
+		if got := negotiate(header); got != want {
+			t.Fatalf("want %q for %q, got %q", want, header, got)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	large := strings.Repeat("compass ", 200)
+	write := func(contentType, encoding, body string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if contentType != "" {
+				rw.Header().Set("Content-Type", contentType)
+			}
+			if encoding != "" {
+				rw.Header().Set("Content-Encoding", encoding)
+			}
+			rw.Header().Set("Content-Length", "1")
+			_, _ = io.WriteString(rw, body[:len(body)/2])
+			_, _ = io.WriteString(rw, body[len(body)/2:])
+		})
+	}
+
+	c, _ := New(WithSkipTypes("application/pdf"))
+	r, _ := compass.New(compass.WithInterceptors(c))
+	_ = r.Get("/large", write("", "", large))
+	_ = r.Get("/small", write("text/plain", "", "small"))
+	_ = r.Get("/image", write("image/png", "", large))
+	_ = r.Get("/svg", write("image/svg+xml", "", large))
+	_ = r.Get("/pdf", write("application/pdf", "", large))
+	_ = r.Get("/encoded", write("text/plain", "br", large))
+	_ = r.Get("/skipped", write("text/plain", "", large), Skip())
+	_ = r.Get("/metadata", write("text/plain", "", large), compass.Metadata("compress", false))
+	_ = r.Get("/empty", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		encoding       string
+		vary           string
+		statusCode     int
+		body           string
+	}{
+		{"/large", "gzip, deflate", "gzip", "Accept-Encoding", 200, large},
+		{"/large", "deflate", "deflate", "Accept-Encoding", 200, large},
+		{"/large", "", "", "Accept-Encoding", 200, large},
+		{"/small", "gzip", "", "Accept-Encoding", 200, "small"},
+		{"/image", "gzip", "", "Accept-Encoding", 200, large},
+		{"/svg", "gzip", "gzip", "Accept-Encoding", 200, large},
+		{"/pdf", "gzip", "", "Accept-Encoding", 200, large},
+		{"/encoded", "gzip", "br", "Accept-Encoding", 200, large},
+		{"/skipped", "gzip", "", "", 200, large},
+		{"/metadata", "gzip", "gzip", "Accept-Encoding", 200, large},
+		{"/empty", "gzip", "", "Accept-Encoding", 204, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path+" "+test.acceptEncoding, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if got := rw.Header().Get("Content-Encoding"); got != test.encoding {
+				t.Fatalf("want encoding %q, but got %q", test.encoding, got)
+			}
+			if got := rw.Header().Get("Vary"); got != test.vary {
+				t.Fatalf("want Vary %q, but got %q", test.vary, got)
+			}
+			if test.encoding == "gzip" || test.encoding == "deflate" {
+				if rw.Header().Get("Content-Length") != "" {
+					t.Fatalf("compressed response must not have Content-Length")
+				}
+			}
+			if got := decode(t, test.encoding, rw.Body.Bytes()); got != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, got)
+			}
+		})
+	}
+}
+
+func TestFlush(t *testing.T) {
+	c, _ := New()
+	var flushed []byte
+	rec := httptest.NewRecorder()
+	h := c.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(rw, "data: 1\n\n")
+		rw.(http.Flusher).Flush()
+		flushed = append(flushed, rec.Body.Bytes()...)
+		_, _ = io.WriteString(rw, "data: 2\n\n")
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rec, req)
+
+	t.Run("flushes the compressed data", func(t *testing.T) {
+		if !rec.Flushed || len(flushed) == 0 {
+			t.Fatalf("small response must be flushed")
+		}
+		zr, _ := gzip.NewReader(bytes.NewReader(flushed))
+		buf := make([]byte, 9)
+		if _, err := io.ReadFull(zr, buf); err != nil || string(buf) != "data: 1\n\n" {
+			t.Fatalf("flushed data must be decodable, got %q (%v)", buf, err)
+		}
+	})
+
+	t.Run("writes the rest of the stream", func(t *testing.T) {
+		if got := decode(t, "gzip", rec.Body.Bytes()); got != "data: 1\n\ndata: 2\n\n" {
+			t.Fatalf("want whole stream, got %q", got)
+		}
+	})
+}
+
+type plainWriter struct {
+	header http.Header
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *plainWriter) WriteHeader(int)             {}
+
+type http1Recorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (http1Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	c, _ := New()
+	tests := []struct {
+		name     string
+		rw       http.ResponseWriter
+		flusher  bool
+		hijacker bool
+	}{
+		{"plain", &plainWriter{header: make(http.Header)}, false, false},
+		{"flusher", httptest.NewRecorder(), true, false},
+		{"http1", http1Recorder{httptest.NewRecorder()}, true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := newWriter(c, test.rw, encodingGzip).wrap()
+			_, isFlusher := w.(http.Flusher)
+			_, isHijacker := w.(http.Hijacker)
+			_, isReaderFrom := w.(io.ReaderFrom)
+			if isFlusher != test.flusher || isHijacker != test.hijacker || isReaderFrom {
+				t.Fatalf("optional interfaces must be preserved")
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("body must be gzip encoded, got %s", err)
+		}
+		r = zr
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("body must be decodable, got %s", err)
+	}
+	return string(b)
+}
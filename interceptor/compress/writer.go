@@ -0,0 +1,185 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compress
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// writer buffers the response until the min size is reached to decide whether
+// the response is compressed
+type writer struct {
+	http.ResponseWriter
+
+	c        *Compress
+	encoding string
+	code     int
+	buf      []byte
+	decided  bool
+	cw       compressor
+}
+
+// flushWriter preserves http.Flusher
+type flushWriter struct {
+	*writer
+}
+
+// http1Writer preserves http.Flusher and http.Hijacker, io.ReaderFrom isn't
+// preserved since it would bypass the compression
+type http1Writer struct {
+	*writer
+}
+
+// http2Writer preserves http.Flusher and http.Pusher
+type http2Writer struct {
+	*writer
+}
+
+func newWriter(c *Compress, rw http.ResponseWriter, encoding string) *writer {
+	return &writer{ResponseWriter: rw, c: c, encoding: encoding}
+}
+
+// wrap returns the writer with the optional interfaces of the underlying
+// writer
+func (w *writer) wrap() http.ResponseWriter {
+	_, isFlusher := w.ResponseWriter.(http.Flusher)
+	_, isHijacker := w.ResponseWriter.(http.Hijacker)
+	_, isPusher := w.ResponseWriter.(http.Pusher)
+
+	switch {
+	case isFlusher && isHijacker:
+		return http1Writer{w}
+	case isFlusher && isPusher:
+		return http2Writer{w}
+	case isFlusher:
+		return flushWriter{w}
+	}
+	return w
+}
+
+// WriteHeader keeps the status code until the compression is decided
+func (w *writer) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+// Write buffers the body until the min size is reached, then writes it to
+// the compressor or the underlying writer
+func (w *writer) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < w.c.minSize {
+		return len(b), nil
+	}
+	if err := w.decide(true); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush implements http.Flusher
+func (w flushWriter) Flush() {
+	w.flush()
+}
+
+// Flush implements http.Flusher
+func (w http1Writer) Flush() {
+	w.flush()
+}
+
+// Flush implements http.Flusher
+func (w http2Writer) Flush() {
+	w.flush()
+}
+
+// flush writes the buffered response and flushes the compressor, a flushed
+// response is compressed regardless of its size
+func (w *writer) flush() {
+	if !w.decided {
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		_ = w.decide(true)
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// Hijack implements http.Hijacker
+func (w http1Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Push implements http.Pusher
+func (w http2Writer) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// close writes the buffered response and releases the compressor
+func (w *writer) close() {
+	if !w.decided && w.code != 0 {
+		_ = w.decide(len(w.buf) >= w.c.minSize)
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+		w.c.put(w.encoding, w.cw)
+		w.cw = nil
+	}
+}
+
+// decide writes the header and the buffered body, the response is compressed
+// when it is allowed and the body isn't already encoded or compressed
+func (w *writer) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get(headerContentType) == "" && len(w.buf) > 0 {
+		header.Set(headerContentType, http.DetectContentType(w.buf))
+	}
+
+	if compress &&
+		hasBody(w.code) &&
+		header.Get(headerContentEncoding) == "" &&
+		header.Get("Content-Range") == "" &&
+		w.c.isCompressible(header.Get(headerContentType)) {
+		header.Set(headerContentEncoding, w.encoding)
+		header.Del(headerContentLength)
+		w.cw = w.c.get(w.encoding, w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.cw != nil {
+		_, err := w.cw.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func hasBody(code int) bool {
+	return code >= http.StatusOK &&
+		code != http.StatusNoContent &&
+		code != http.StatusPartialContent &&
+		code != http.StatusNotModified
+}
@@ -0,0 +1,342 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package cors provides a Cross-Origin Resource Sharing interceptor which
+// answers preflight requests with the methods registered on the router.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mustafaturan/compass"
+)
+
+// CORS is an interceptor which applies Cross-Origin Resource Sharing policies
+type CORS struct {
+	allowAllOrigins  bool
+	origins          map[string]struct{}
+	wildcards        []wildcard
+	originFunc       func(origin string) bool
+	methods          []string
+	allowAllHeaders  bool
+	headers          map[string]struct{}
+	exposedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+	groups           []group
+}
+
+// Option is a CORS option
+type Option func(*CORS) error
+
+// wildcard is an origin pattern with a subdomain wildcard like
+// `https://*.example.com`
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+// group is the policy of a path prefix
+type group struct {
+	prefix string
+	cors   *CORS
+}
+
+const (
+	headerOrigin           = "Origin"
+	headerVary             = "Vary"
+	headerRequestMethod    = "Access-Control-Request-Method"
+	headerRequestHeaders   = "Access-Control-Request-Headers"
+	headerAllowOrigin      = "Access-Control-Allow-Origin"
+	headerAllowMethods     = "Access-Control-Allow-Methods"
+	headerAllowHeaders     = "Access-Control-Allow-Headers"
+	headerAllowCredentials = "Access-Control-Allow-Credentials"
+	headerExposeHeaders    = "Access-Control-Expose-Headers"
+	headerMaxAge           = "Access-Control-Max-Age"
+
+	matchall = "*"
+)
+
+// DefaultHeaders are the request headers which are allowed unless WithHeaders
+// option is given
+var DefaultHeaders = []string{
+	"Accept", "Accept-Language", "Content-Language", "Content-Type",
+}
+
+// New returns a new CORS interceptor, no origins are allowed unless WithOrigins
+// or WithOriginFunc option is given
+func New(options ...Option) (*CORS, error) {
+	c := &CORS{
+		origins: make(map[string]struct{}),
+		headers: make(map[string]struct{}),
+	}
+	for _, h := range DefaultHeaders {
+		c.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// WithOrigins option sets the allowed origins. The origins can be exact
+// origins like `https://example.com`, subdomain wildcards like
+// `https://*.example.com` or `*` to allow all origins.
+func WithOrigins(origins ...string) Option {
+	return func(c *CORS) error {
+		for _, origin := range origins {
+			origin = strings.ToLower(origin)
+			switch i := strings.Index(origin, "*"); {
+			case origin == matchall:
+				c.allowAllOrigins = true
+			case i < 0:
+				c.origins[origin] = struct{}{}
+			case isSubdomainWildcard(origin, i):
+				c.wildcards = append(c.wildcards, wildcard{
+					prefix: origin[:i],
+					suffix: origin[i+1:],
+				})
+			default:
+				return errors.New("origin wildcard must be a subdomain wildcard")
+			}
+		}
+		return nil
+	}
+}
+
+// WithOriginFunc option sets a predicate which allows the origins in addition
+// to the origins of WithOrigins option
+func WithOriginFunc(fn func(origin string) bool) Option {
+	return func(c *CORS) error {
+		if fn == nil {
+			return errors.New("origin func can't be nil")
+		}
+		c.originFunc = fn
+		return nil
+	}
+}
+
+// WithMethods option sets the allowed methods, by default the methods which
+// are registered on the router for the request path are allowed
+func WithMethods(methods ...string) Option {
+	return func(c *CORS) error {
+		if len(methods) == 0 {
+			return errors.New("methods can't be empty")
+		}
+		c.methods = make([]string, len(methods))
+		for i, m := range methods {
+			c.methods[i] = strings.ToUpper(m)
+		}
+		sort.Strings(c.methods)
+		return nil
+	}
+}
+
+// WithHeaders option sets the allowed request headers, `*` allows all request
+// headers
+func WithHeaders(headers ...string) Option {
+	return func(c *CORS) error {
+		c.headers = make(map[string]struct{}, len(headers))
+		for _, h := range headers {
+			if h == matchall {
+				c.allowAllHeaders = true
+				continue
+			}
+			c.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithExposedHeaders option sets the response headers which are exposed to
+// the clients
+func WithExposedHeaders(headers ...string) Option {
+	return func(c *CORS) error {
+		c.exposedHeaders = make([]string, len(headers))
+		for i, h := range headers {
+			c.exposedHeaders[i] = http.CanonicalHeaderKey(h)
+		}
+		return nil
+	}
+}
+
+// WithCredentials option allows the requests with credentials, the request
+// origin is echoed instead of `*` when all origins are allowed
+func WithCredentials() Option {
+	return func(c *CORS) error {
+		c.allowCredentials = true
+		return nil
+	}
+}
+
+// WithMaxAge option sets how long the preflight responses can be cached
+func WithMaxAge(d time.Duration) Option {
+	return func(c *CORS) error {
+		if d < time.Second {
+			return errors.New("max age must be at least a second")
+		}
+		c.maxAge = d
+		return nil
+	}
+}
+
+// WithGroup option applies a separate policy built with the given options to
+// the requests under the path prefix like a route group. The policy of the
+// longest matching prefix is applied.
+func WithGroup(prefix string, options ...Option) Option {
+	return func(c *CORS) error {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == "" || prefix[0] != '/' {
+			return errors.New("group prefix must start with '/' char")
+		}
+		g, err := New(options...)
+		if err != nil {
+			return err
+		}
+		c.groups = append(c.groups, group{prefix: prefix, cors: g})
+		sort.SliceStable(c.groups, func(i, j int) bool {
+			return len(c.groups[i].prefix) > len(c.groups[j].prefix)
+		})
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (c *CORS) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get(headerOrigin)
+		if origin == "" {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		p := c.policy(req.URL.Path)
+		rw.Header().Add(headerVary, headerOrigin)
+		if req.Method == http.MethodOptions && req.Header.Get(headerRequestMethod) != "" {
+			p.preflight(rw, req, origin)
+			return
+		}
+
+		if p.isAllowedOrigin(origin) {
+			p.writeOrigin(rw, origin)
+			if len(p.exposedHeaders) > 0 {
+				rw.Header().Set(headerExposeHeaders, strings.Join(p.exposedHeaders, ", "))
+			}
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// preflight answers the preflight request, the disallowed requests are
+// rejected with 403
+func (c *CORS) preflight(rw http.ResponseWriter, req *http.Request, origin string) {
+	header := rw.Header()
+	header.Add(headerVary, headerRequestMethod)
+	header.Add(headerVary, headerRequestHeaders)
+
+	methods := c.methods
+	if methods == nil {
+		methods = compass.AllowedMethods(req)
+	}
+	method := strings.ToUpper(req.Header.Get(headerRequestMethod))
+	headers, headersAllowed := c.allowedHeaders(req.Header.Get(headerRequestHeaders))
+	if !c.isAllowedOrigin(origin) || !contains(methods, method) || !headersAllowed {
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	c.writeOrigin(rw, origin)
+	header.Set(headerAllowMethods, strings.Join(methods, ", "))
+	if len(headers) > 0 {
+		header.Set(headerAllowHeaders, strings.Join(headers, ", "))
+	}
+	if c.maxAge > 0 {
+		header.Set(headerMaxAge, strconv.Itoa(int(c.maxAge/time.Second)))
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) writeOrigin(rw http.ResponseWriter, origin string) {
+	header := rw.Header()
+	if c.allowAllOrigins && !c.allowCredentials {
+		header.Set(headerAllowOrigin, matchall)
+	} else {
+		header.Set(headerAllowOrigin, origin)
+	}
+	if c.allowCredentials {
+		header.Set(headerAllowCredentials, "true")
+	}
+}
+
+// policy returns the policy of the longest matching group prefix
+func (c *CORS) policy(path string) *CORS {
+	for _, g := range c.groups {
+		if path == g.prefix || strings.HasPrefix(path, g.prefix+"/") {
+			return g.cors.policy(path)
+		}
+	}
+	return c
+}
+
+func (c *CORS) isAllowedOrigin(origin string) bool {
+	if c.allowAllOrigins {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := c.origins[lower]; ok {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if len(lower) > len(w.prefix)+len(w.suffix) &&
+			strings.HasPrefix(lower, w.prefix) &&
+			strings.HasSuffix(lower, w.suffix) {
+			return true
+		}
+	}
+	return c.originFunc != nil && c.originFunc(origin)
+}
+
+// allowedHeaders returns the canonical requested headers and reports whether
+// all of them are allowed
+func (c *CORS) allowedHeaders(requested string) ([]string, bool) {
+	headers := make([]string, 0)
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		h = http.CanonicalHeaderKey(h)
+		if _, ok := c.headers[h]; !ok && !c.allowAllHeaders {
+			return nil, false
+		}
+		headers = append(headers, h)
+	}
+	return headers, true
+}
+
+// isSubdomainWildcard reports whether the only wildcard of the origin is at
+// the beginning of the host
+func isSubdomainWildcard(origin string, i int) bool {
+	return strings.Count(origin, "*") == 1 &&
+		strings.HasSuffix(origin[:i], "://") &&
+		strings.HasPrefix(origin[i:], "*.")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
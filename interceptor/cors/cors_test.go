@@ -0,0 +1,190 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		option Option
+		err    string
+	}{
+		{WithOrigins("https://*.*.example.com"), "origin wildcard must be a subdomain wildcard"},
+		{WithOrigins("https://example*.com"), "origin wildcard must be a subdomain wildcard"},
+		{WithOriginFunc(nil), "origin func can't be nil"},
+		{WithMethods(), "methods can't be empty"},
+		{WithMaxAge(time.Millisecond), "max age must be at least a second"},
+		{WithGroup("api"), "group prefix must start with '/' char"},
+		{WithGroup("/api", WithOriginFunc(nil)), "origin func can't be nil"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	c, _ := New(
+		WithOrigins("https://example.com", "https://*.example.org"),
+		WithOriginFunc(func(origin string) bool { return origin == "https://partner.io" }),
+		WithHeaders("Content-Type", "X-Token"),
+		WithExposedHeaders("x-request-id"),
+		WithMaxAge(10*time.Minute),
+		WithGroup("/public/",
+			WithOrigins("*"),
+			WithMethods("get"),
+			WithHeaders("*"),
+		),
+		WithGroup("/public/private", WithOrigins("https://example.com"), WithCredentials()),
+	)
+	r, _ := compass.New(compass.WithInterceptors(c))
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	})
+	_ = r.Get("/posts/:id", ok)
+	_ = r.Delete("/posts/:id", ok)
+	_ = r.Get("/public/feed", ok)
+	_ = r.Get("/public/private/feed", ok)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		statusCode int
+		want       map[string]string
+	}{
+		{
+			"request without origin",
+			"GET", "/posts/1", nil, http.StatusOK,
+			map[string]string{headerAllowOrigin: "", headerVary: ""},
+		},
+		{
+			"request from allowed origin",
+			"GET", "/posts/1",
+			map[string]string{headerOrigin: "https://example.com"},
+			http.StatusOK,
+			map[string]string{
+				headerAllowOrigin:   "https://example.com",
+				headerExposeHeaders: "X-Request-Id",
+				headerVary:          "Origin",
+			},
+		},
+		{
+			"request from disallowed origin",
+			"GET", "/posts/1",
+			map[string]string{headerOrigin: "https://evil.com"},
+			http.StatusOK,
+			map[string]string{headerAllowOrigin: "", headerExposeHeaders: ""},
+		},
+		{
+			"preflight with registered methods",
+			"OPTIONS", "/posts/1",
+			map[string]string{
+				headerOrigin:         "https://api.example.org",
+				headerRequestMethod:  "DELETE",
+				headerRequestHeaders: "x-token, content-type",
+			},
+			http.StatusNoContent,
+			map[string]string{
+				headerAllowOrigin:  "https://api.example.org",
+				headerAllowMethods: "DELETE, GET",
+				headerAllowHeaders: "X-Token, Content-Type",
+				headerMaxAge:       "600",
+			},
+		},
+		{
+			"preflight from origin func",
+			"OPTIONS", "/posts/1",
+			map[string]string{
+				headerOrigin:        "https://partner.io",
+				headerRequestMethod: "GET",
+			},
+			http.StatusNoContent,
+			map[string]string{headerAllowOrigin: "https://partner.io"},
+		},
+		{
+			"preflight with unregistered method",
+			"OPTIONS", "/posts/1",
+			map[string]string{
+				headerOrigin:        "https://example.com",
+				headerRequestMethod: "PUT",
+			},
+			http.StatusForbidden,
+			map[string]string{headerAllowOrigin: "", headerAllowMethods: ""},
+		},
+		{
+			"preflight with disallowed header",
+			"OPTIONS", "/posts/1",
+			map[string]string{
+				headerOrigin:         "https://example.com",
+				headerRequestMethod:  "GET",
+				headerRequestHeaders: "X-Other",
+			},
+			http.StatusForbidden,
+			map[string]string{headerAllowOrigin: ""},
+		},
+		{
+			"preflight from bare wildcard domain",
+			"OPTIONS", "/posts/1",
+			map[string]string{
+				headerOrigin:        "https://.example.org",
+				headerRequestMethod: "GET",
+			},
+			http.StatusForbidden,
+			map[string]string{headerAllowOrigin: ""},
+		},
+		{
+			"group preflight",
+			"OPTIONS", "/public/feed",
+			map[string]string{
+				headerOrigin:         "https://any.io",
+				headerRequestMethod:  "GET",
+				headerRequestHeaders: "X-Anything",
+			},
+			http.StatusNoContent,
+			map[string]string{
+				headerAllowOrigin:  "*",
+				headerAllowMethods: "GET",
+				headerAllowHeaders: "X-Anything",
+				headerMaxAge:       "",
+			},
+		},
+		{
+			"nested group request",
+			"GET", "/public/private/feed",
+			map[string]string{headerOrigin: "https://example.com"},
+			http.StatusOK,
+			map[string]string{
+				headerAllowOrigin:      "https://example.com",
+				headerAllowCredentials: "true",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "http://example.com"+test.path, nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			for k, v := range test.want {
+				if got := rw.Header().Get(k); got != v {
+					t.Fatalf("want %s header %q, but got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package etag provides an interceptor which generates entity tags for the
+// responses and evaluates the conditional request headers.
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// ETag is an interceptor which buffers the GET and HEAD responses to generate
+// entity tags and answers the conditional requests with 304 or 412
+type ETag struct {
+	weak     bool
+	resolver http.Handler
+	handler  http.Handler
+}
+
+// Option is an etag option
+type Option func(*ETag) error
+
+// validators are the entity tag and the modification time of a representation
+type validators struct {
+	exists       bool
+	etag         string
+	lastModified time.Time
+}
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const (
+	headerETag              = "ETag"
+	headerLastModified      = "Last-Modified"
+	headerIfMatch           = "If-Match"
+	headerIfNoneMatch       = "If-None-Match"
+	headerIfModifiedSince   = "If-Modified-Since"
+	headerIfUnmodifiedSince = "If-Unmodified-Since"
+	headerIfRange           = "If-Range"
+
+	settingEnabled = settingKey(0)
+)
+
+// New returns a new etag interceptor which generates strong entity tags
+// unless the weak ones are enabled
+func New(options ...Option) (*ETag, error) {
+	e := &ETag{
+		handler: chandler.Status{Code: http.StatusPreconditionFailed},
+	}
+
+	for _, o := range options {
+		if err := o(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// WithWeak option generates weak entity tags, they must be used when the
+// representations are modified after the interceptor like compressing them
+func WithWeak() Option {
+	return func(e *ETag) error {
+		e.weak = true
+		return nil
+	}
+}
+
+// WithResolver option sets the handler, typically the router itself, which
+// serves GET subrequests to find the current validators of the resources for
+// the preconditions of the unsafe methods like `If-Match` of PUT requests.
+// The preconditions of the unsafe methods aren't evaluated without a
+// resolver. The subrequests are marked with interceptor.WithSubrequest, so the
+// rate limit, bulkhead, metrics, access log and cache interceptors skip them.
+//
+// The preconditions are evaluated before the handler runs without locking the
+// resource, so a concurrent update between the subrequest and the handler
+// isn't detected; the handlers which need atomic updates must check the
+// version of the resource while they update it.
+func WithResolver(h http.Handler) Option {
+	return func(e *ETag) error {
+		if h == nil {
+			return errors.New("resolver can't be nil")
+		}
+		e.resolver = h
+		return nil
+	}
+}
+
+// WithHandler option sets the handler of the failed preconditions, the
+// default handler responds with 412
+func WithHandler(h http.Handler) Option {
+	return func(e *ETag) error {
+		if h == nil {
+			return errors.New("handler can't be nil")
+		}
+		e.handler = h
+		return nil
+	}
+}
+
+// Skip route option disables the interceptor for the route, like for the
+// streaming responses which can't be buffered
+func Skip() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		h.SetSetting(settingEnabled, false)
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (e *ETag) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if enabled, ok := compass.Route(req.Context()).Setting(settingEnabled).(bool); ok && !enabled {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+			e.serveSafe(h, rw, req)
+		case http.MethodOptions, http.MethodTrace:
+			h.ServeHTTP(rw, req)
+		default:
+			e.serveUnsafe(h, rw, req)
+		}
+	})
+}
+
+// serveSafe buffers the response to set its entity tag and replaces it with
+// 304 or 412 when the preconditions fail
+func (e *ETag) serveSafe(h http.Handler, rw http.ResponseWriter, req *http.Request) {
+	w := cinterceptor.NewBufferWriter()
+	h.ServeHTTP(w, req)
+
+	// the preconditions are only evaluated for the successful responses
+	if w.Status() == http.StatusOK && w.Header().Get(headerETag) == "" &&
+		(req.Method == http.MethodGet || len(w.Bytes()) > 0) {
+		w.Header().Set(headerETag, e.generate(w.Bytes()))
+	}
+	if w.Status() < 200 || w.Status() > 299 {
+		w.WriteTo(rw)
+		return
+	}
+
+	switch code := evaluate(req, validatorsOf(w)); code {
+	case http.StatusNotModified:
+		writeNotModified(rw, w.Header())
+	case http.StatusPreconditionFailed:
+		e.preconditionFailed(rw, req)
+	default:
+		w.WriteTo(rw)
+	}
+}
+
+// serveUnsafe resolves the current validators of the resource with a GET
+// subrequest when the request has preconditions
+func (e *ETag) serveUnsafe(h http.Handler, rw http.ResponseWriter, req *http.Request) {
+	if e.resolver == nil || !hasPreconditions(req) {
+		h.ServeHTTP(rw, req)
+		return
+	}
+
+	if evaluate(req, e.resolve(req)) != 0 {
+		e.preconditionFailed(rw, req)
+		return
+	}
+	h.ServeHTTP(rw, req)
+}
+
+// resolve serves a GET request for the same URL without the body and the
+// preconditions to the resolver and returns the validators of the response
+func (e *ETag) resolve(req *http.Request) validators {
+	sub := req.Clone(cinterceptor.WithSubrequest(req.Context()))
+	sub.Method = http.MethodGet
+	sub.Body = http.NoBody
+	sub.ContentLength = 0
+	for _, k := range []string{
+		headerIfMatch, headerIfNoneMatch, headerIfModifiedSince,
+		headerIfUnmodifiedSince, headerIfRange, "Content-Type", "Content-Length",
+	} {
+		sub.Header.Del(k)
+	}
+
+	w := cinterceptor.NewBufferWriter()
+	e.resolver.ServeHTTP(w, sub)
+	if w.Status() < 200 || w.Status() > 299 {
+		return validators{}
+	}
+	if w.Header().Get(headerETag) == "" {
+		w.Header().Set(headerETag, e.generate(w.Bytes()))
+	}
+	return validatorsOf(w)
+}
+
+func (e *ETag) preconditionFailed(rw http.ResponseWriter, req *http.Request) {
+	ctx := chandler.WithReason(req.Context(), "precondition failed for "+req.URL.Path)
+	e.handler.ServeHTTP(rw, req.WithContext(ctx))
+}
+
+// generate returns the entity tag of the body
+func (e *ETag) generate(body []byte) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if e.weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// validatorsOf returns the validators of the buffered response
+func validatorsOf(w *cinterceptor.BufferWriter) validators {
+	header := w.Header()
+	lastModified, _ := parseTime(header.Get(headerLastModified))
+	return validators{
+		exists:       true,
+		etag:         header.Get(headerETag),
+		lastModified: lastModified,
+	}
+}
+
+// writeNotModified writes 304 with the headers of the response except the
+// representation metadata
+func writeNotModified(rw http.ResponseWriter, header http.Header) {
+	dst := rw.Header()
+	for k, v := range header {
+		dst[k] = v
+	}
+	dst.Del("Content-Type")
+	dst.Del("Content-Length")
+	dst.Del("Content-Encoding")
+	if dst.Get(headerETag) != "" {
+		dst.Del(headerLastModified)
+	}
+	rw.WriteHeader(http.StatusNotModified)
+}
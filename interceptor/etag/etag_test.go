@@ -0,0 +1,253 @@
+package etag
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	"github.com/mustafaturan/compass/interceptor/accesslog"
+	"github.com/mustafaturan/compass/interceptor/ratelimit"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		option Option
+		err    string
+	}{
+		{WithResolver(nil), "resolver can't be nil"},
+		{WithHandler(nil), "handler can't be nil"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		header  string
+		current string
+		strong  bool
+		weak    bool
+	}{
+		{`"a"`, `"a"`, true, true},
+		{`"b", "a"`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, false, true},
+		{`"a"`, `W/"a"`, false, true},
+		{`*`, `"a"`, true, true},
+		{`"a,b"`, `"a,b"`, true, true},
+		{`"b"`, `"a"`, false, false},
+		{`a`, `"a"`, false, false},
+		{`"a`, `"a"`, false, false},
+		{`"a"`, ``, false, false},
+	}
+	for _, test := range tests {
+		if got := matches(test.header, test.current, strongCompare); got != test.strong {
+			t.Fatalf("want strong match %v for %s and %s, got %v", test.strong, test.header, test.current, got)
+		}
+		if got := matches(test.header, test.current, weakCompare); got != test.weak {
+			t.Fatalf("want weak match %v for %s and %s, got %v", test.weak, test.header, test.current, got)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	modified := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	body := "hello"
+	tag := `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`
+
+	var puts int
+	e, _ := New()
+	r, _ := compass.New(compass.WithInterceptors(e))
+	_ = WithResolver(r)(e)
+
+	_ = r.Get("/generated", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(rw, body)
+	}))
+	_ = r.Get("/provided", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("ETag", `W/"v1"`)
+		rw.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		_, _ = io.WriteString(rw, body)
+	}))
+	_ = r.Get("/missing", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "missing", http.StatusNotFound)
+	}))
+	_ = r.Get("/skipped", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, body)
+	}), Skip())
+	_ = r.Get("/metadata", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, body)
+	}), compass.Metadata("etag", false))
+	put := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		puts++
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	_ = r.Put("/generated", put)
+	_ = r.Put("/provided", put)
+	_ = r.Put("/missing", put)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     http.Header
+		statusCode int
+		etag       string
+		body       string
+		puts       int
+	}{
+		{"generates etag", "GET", "/generated", nil, 200, tag, body, 0},
+		{"handler provided etag", "GET", "/provided", nil, 200, `W/"v1"`, body, 0},
+		{"skipped route", "GET", "/skipped", nil, 200, "", body, 0},
+		{"metadata doesn't skip", "GET", "/metadata", nil, 200, tag, body, 0},
+		{"error response", "GET", "/missing", http.Header{"If-None-Match": {"*"}}, 404, "", "missing\n", 0},
+		{"if-none-match", "GET", "/generated", http.Header{"If-None-Match": {`"x", ` + tag}}, 304, tag, "", 0},
+		{"if-none-match weak", "GET", "/provided", http.Header{"If-None-Match": {`"v1"`}}, 304, `W/"v1"`, "", 0},
+		{"if-none-match any", "GET", "/provided", http.Header{"If-None-Match": {"*"}}, 304, `W/"v1"`, "", 0},
+		{"if-none-match changed", "GET", "/generated", http.Header{"If-None-Match": {`"x"`}}, 200, tag, body, 0},
+		{
+			"if-modified-since", "GET", "/provided",
+			http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}},
+			304, `W/"v1"`, "", 0,
+		},
+		{
+			"if-modified-since changed", "GET", "/provided",
+			http.Header{"If-Modified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}},
+			200, `W/"v1"`, body, 0,
+		},
+		{
+			"if-none-match takes precedence", "GET", "/provided",
+			http.Header{"If-None-Match": {`"v2"`}, "If-Modified-Since": {modified.Format(http.TimeFormat)}},
+			200, `W/"v1"`, body, 0,
+		},
+		{"if-match", "GET", "/generated", http.Header{"If-Match": {`"x"`}}, 412, "", "", 0},
+		{"put if-match", "PUT", "/generated", http.Header{"If-Match": {tag}}, 204, "", "", 1},
+		{"put if-match changed", "PUT", "/generated", http.Header{"If-Match": {`"x"`}}, 412, "", "", 1},
+		{"put if-match weak", "PUT", "/provided", http.Header{"If-Match": {`W/"v1"`}}, 412, "", "", 1},
+		{"put if-match any", "PUT", "/generated", http.Header{"If-Match": {"*"}}, 204, "", "", 2},
+		{"put if-match missing", "PUT", "/missing", http.Header{"If-Match": {"*"}}, 412, "", "", 2},
+		{"put if-none-match any", "PUT", "/generated", http.Header{"If-None-Match": {"*"}}, 412, "", "", 2},
+		{"put if-none-match missing", "PUT", "/missing", http.Header{"If-None-Match": {"*"}}, 204, "", "", 3},
+		{
+			"put if-unmodified-since", "PUT", "/provided",
+			http.Header{"If-Unmodified-Since": {modified.Format(http.TimeFormat)}},
+			204, "", "", 4,
+		},
+		{
+			"put if-unmodified-since changed", "PUT", "/provided",
+			http.Header{"If-Unmodified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}},
+			412, "", "", 4,
+		},
+		{"put without preconditions", "PUT", "/missing", nil, 204, "", "", 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "http://example.com"+test.path, strings.NewReader("{}"))
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if got := rw.Header().Get("ETag"); got != test.etag {
+				t.Fatalf("want ETag %s, but got %s", test.etag, got)
+			}
+			if test.statusCode != http.StatusPreconditionFailed && rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+			if rw.Code == http.StatusNotModified && rw.Header().Get("Content-Type") != "" {
+				t.Fatalf("304 response must not have Content-Type")
+			}
+			if puts != test.puts {
+				t.Fatalf("want %d PUT handler calls, but got %d", test.puts, puts)
+			}
+		})
+	}
+}
+
+func TestWithWeak(t *testing.T) {
+	e, _ := New(WithWeak())
+	h := e.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "hello")
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("If-None-Match", `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if got := rw.Header().Get("ETag"); got != `W/"2cf24dba5fb0a30e26e83b2ac5b9e29e"` {
+		t.Fatalf("want weak ETag, but got %s", got)
+	}
+	if rw.Code != http.StatusNotModified {
+		t.Fatalf("want status code 304, but got %d", rw.Code)
+	}
+}
+
+func TestWithoutResolver(t *testing.T) {
+	e, _ := New(WithHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusConflict)
+	})))
+	h := e.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Run("unsafe methods pass through", func(t *testing.T) {
+		req := httptest.NewRequest("PUT", "http://example.com/", nil)
+		req.Header.Set("If-Match", `"x"`)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if rw.Code != http.StatusNoContent {
+			t.Fatalf("want status code 204, but got %d", rw.Code)
+		}
+	})
+
+	t.Run("custom handler", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "http://example.com/", nil)
+		req.Header.Set("If-Match", `"x"`)
+		rw := httptest.NewRecorder()
+		_ = WithResolver(http.NotFoundHandler())(e)
+		h.ServeHTTP(rw, req)
+		if rw.Code != http.StatusConflict {
+			t.Fatalf("want status code 409, but got %d", rw.Code)
+		}
+	})
+}
+
+func TestSubrequests(t *testing.T) {
+	var entries []accesslog.Entry
+	logger := accesslog.LoggerFunc(func(e accesslog.Entry) { entries = append(entries, e) })
+	a, _ := accesslog.New(accesslog.WithLogger(logger))
+	rl, _ := ratelimit.New(ratelimit.Limit{Rate: 1, Period: time.Minute, Burst: 1})
+	e, _ := New()
+	r, _ := compass.New(compass.WithInterceptors(a, rl, e))
+	_ = WithResolver(r)(e)
+
+	_ = r.Get("/resource", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("ETag", `"v1"`)
+	}))
+	_ = r.Put("/resource", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest("PUT", "http://example.com/resource", nil)
+	req.Header.Set("If-Match", `"v1"`)
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, req)
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("subrequests must not be rate limited, got %d", rw.Code)
+	}
+	if len(entries) != 1 || entries[0].Method != "PUT" {
+		t.Fatalf("subrequests must not be logged: %+v", entries)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package etag
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hasPreconditions reports whether the request has any of the precondition
+// headers evaluated for the unsafe methods
+func hasPreconditions(req *http.Request) bool {
+	for _, k := range []string{headerIfMatch, headerIfNoneMatch, headerIfUnmodifiedSince} {
+		if req.Header.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate evaluates the preconditions of the request in the RFC 7232 order
+// against the current validators, it returns 304 or 412 when a precondition
+// fails and 0 otherwise
+func evaluate(req *http.Request, v validators) int {
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	if ifMatch := req.Header.Get(headerIfMatch); ifMatch != "" {
+		if !v.exists || !matches(ifMatch, v.etag, strongCompare) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := parseTime(req.Header.Get(headerIfUnmodifiedSince)); ok {
+		if v.exists && modifiedSince(v.lastModified, since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := req.Header.Get(headerIfNoneMatch); ifNoneMatch != "" {
+		if v.exists && matches(ifNoneMatch, v.etag, weakCompare) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := parseTime(req.Header.Get(headerIfModifiedSince)); ok && safe {
+		if v.exists && !v.lastModified.IsZero() && !modifiedSince(v.lastModified, since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matches reports whether the header, a list of entity tags or `*`, has an
+// entity tag matching the current one with the comparison function
+func matches(header, current string, compare func(a, b string) bool) bool {
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			break
+		}
+		if header[0] == '*' {
+			return true
+		}
+		tag, rest := scanETag(header)
+		if tag == "" {
+			return false
+		}
+		if current != "" && compare(tag, current) {
+			return true
+		}
+		header = rest
+	}
+	return false
+}
+
+// scanETag returns the first entity tag of the list and the rest of it
+func scanETag(s string) (string, string) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s) <= start || s[start] != '"' {
+		return "", ""
+	}
+	end := strings.IndexByte(s[start+1:], '"')
+	if end < 0 {
+		return "", ""
+	}
+	end += start + 2
+	return s[:end], s[end:]
+}
+
+// strongCompare reports whether both entity tags are strong and identical
+func strongCompare(a, b string) bool {
+	return a == b && !strings.HasPrefix(a, "W/")
+}
+
+// weakCompare reports whether the opaque tags are identical regardless of
+// their weakness
+func weakCompare(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// modifiedSince compares the times in seconds since the HTTP dates don't have
+// sub-second precision
+func modifiedSince(lastModified, since time.Time) bool {
+	return lastModified.Truncate(time.Second).After(since)
+}
+
+func parseTime(v string) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(v)
+	return t, err == nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package metrics provides an interceptor which records HTTP request metrics
+// labeled by method, matched route pattern and status class, and serves them
+// in Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// Metrics is an interceptor which records request count, in-flight requests,
+// latency and response size
+type Metrics struct {
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+
+	mu        sync.Mutex
+	requests  map[labels]uint64
+	inflight  map[routeLabels]int64
+	durations map[labels]*histogram
+	sizes     map[labels]*histogram
+}
+
+// Option is a metrics option
+type Option func(*Metrics) error
+
+type routeLabels struct {
+	method string
+	route  string
+}
+
+type labels struct {
+	routeLabels
+	status string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+const (
+	// NotFoundRoute is the route label of the requests which don't match any
+	// route
+	NotFoundRoute = "notfound"
+
+	// OtherMethod is the method label of the requests with non-standard
+	// methods, it bounds the number of the time series
+	OtherMethod = "other"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// DefaultDurationBuckets are the default latency buckets in seconds
+	DefaultDurationBuckets = []float64{
+		0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+	}
+
+	// DefaultSizeBuckets are the default response size buckets in bytes
+	DefaultSizeBuckets = []float64{
+		100, 1000, 10000, 100000, 1000000, 10000000,
+	}
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// New returns a new metrics interceptor
+func New(options ...Option) (*Metrics, error) {
+	m := &Metrics{
+		durationBuckets: DefaultDurationBuckets,
+		sizeBuckets:     DefaultSizeBuckets,
+		requests:        make(map[labels]uint64),
+		inflight:        make(map[routeLabels]int64),
+		durations:       make(map[labels]*histogram),
+		sizes:           make(map[labels]*histogram),
+	}
+
+	for _, o := range options {
+		if err := o(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// WithNamespace option prefixes the metric names with the namespace
+func WithNamespace(namespace string) Option {
+	return func(m *Metrics) error {
+		if namespace == "" {
+			return errors.New("namespace can't be empty")
+		}
+		m.namespace = namespace
+		return nil
+	}
+}
+
+// WithDurationBuckets option sets the latency histogram buckets in seconds
+func WithDurationBuckets(buckets ...float64) Option {
+	return func(m *Metrics) error {
+		if !isSorted(buckets) {
+			return errors.New("duration buckets must be in increasing order")
+		}
+		m.durationBuckets = buckets
+		return nil
+	}
+}
+
+// WithSizeBuckets option sets the response size histogram buckets in bytes
+func WithSizeBuckets(buckets ...float64) Option {
+	return func(m *Metrics) error {
+		if !isSorted(buckets) {
+			return errors.New("size buckets must be in increasing order")
+		}
+		m.sizeBuckets = buckets
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (m *Metrics) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if cinterceptor.IsSubrequest(req.Context()) {
+			h.ServeHTTP(rw, req)
+			return
+		}
+		rl := routeLabels{method: methodLabel(req), route: routeLabel(req)}
+		m.track(rl, 1)
+
+		start := time.Now()
+		w := cinterceptor.WrapResponseWriter(rw)
+		defer func() {
+			// the panics are recovered by the router with 500 unless the
+			// header is already written
+			recovered := recover()
+			status := w.Status()
+			switch {
+			case recovered != nil && !w.Written():
+				status = http.StatusInternalServerError
+			case status == 0:
+				status = http.StatusOK
+			}
+			m.track(rl, -1)
+			m.observe(
+				labels{routeLabels: rl, status: statusClass(status)},
+				time.Since(start).Seconds(),
+				float64(w.BytesWritten()),
+			)
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+
+		h.ServeHTTP(w, req)
+	})
+}
+
+// Handler returns an http.Handler which serves the metrics in Prometheus text
+// exposition format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", contentType)
+		w := bufio.NewWriter(rw)
+		m.write(w)
+		_ = w.Flush()
+	})
+}
+
+func (m *Metrics) track(rl routeLabels, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inflight[rl] += delta
+}
+
+func (m *Metrics) observe(l labels, duration, size float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[l]++
+	if _, ok := m.durations[l]; !ok {
+		m.durations[l] = newHistogram(m.durationBuckets)
+		m.sizes[l] = newHistogram(m.sizeBuckets)
+	}
+	m.durations[l].observe(duration)
+	m.sizes[l].observe(size)
+}
+
+func (m *Metrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := sortedLabels(m.requests)
+
+	name := m.name("http_requests_total")
+	writeMeta(w, name, "Total number of HTTP requests.", "counter")
+	for _, l := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, l.String(), m.requests[l])
+	}
+
+	name = m.name("http_requests_in_flight")
+	writeMeta(w, name, "Number of HTTP requests being served.", "gauge")
+	inflight := make([]routeLabels, 0, len(m.inflight))
+	for rl := range m.inflight {
+		inflight = append(inflight, rl)
+	}
+	sort.Slice(inflight, func(i, j int) bool {
+		return inflight[i].String() < inflight[j].String()
+	})
+	for _, rl := range inflight {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, rl.String(), m.inflight[rl])
+	}
+
+	name = m.name("http_request_duration_seconds")
+	writeMeta(w, name, "HTTP request latencies in seconds.", "histogram")
+	for _, l := range keys {
+		m.durations[l].write(w, name, l.String())
+	}
+
+	name = m.name("http_response_size_bytes")
+	writeMeta(w, name, "HTTP response sizes in bytes.", "histogram")
+	for _, l := range keys {
+		m.sizes[l].write(w, name, l.String())
+	}
+}
+
+func (m *Metrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w *bufio.Writer, name, labels string) {
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n",
+			name, labels, formatFloat(upper), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// String returns labels in exposition format
+func (rl routeLabels) String() string {
+	return fmt.Sprintf(`method="%s",route="%s"`,
+		labelEscaper.Replace(rl.method), labelEscaper.Replace(rl.route))
+}
+
+// String returns labels in exposition format
+func (l labels) String() string {
+	return fmt.Sprintf(`%s,status="%s"`, l.routeLabels.String(), l.status)
+}
+
+func writeMeta(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func sortedLabels(m map[labels]uint64) []labels {
+	keys := make([]labels, 0, len(m))
+	for l := range m {
+		keys = append(keys, l)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func methodLabel(req *http.Request) string {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return req.Method
+	}
+	return OtherMethod
+}
+
+func routeLabel(req *http.Request) string {
+	route := compass.Route(req.Context())
+	if !route.Found() {
+		return NotFoundRoute
+	}
+	return route.Pattern
+}
+
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func isSorted(buckets []float64) bool {
+	if len(buckets) == 0 {
+		return false
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return false
+		}
+	}
+	return true
+}
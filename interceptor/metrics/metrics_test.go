@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		option Option
+		err    string
+	}{
+		{WithNamespace(""), "namespace can't be empty"},
+		{WithDurationBuckets(), "duration buckets must be in increasing order"},
+		{WithDurationBuckets(1, 0.5), "duration buckets must be in increasing order"},
+		{WithSizeBuckets(10, 10), "size buckets must be in increasing order"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m, _ := New(
+		WithNamespace("blog"),
+		WithDurationBuckets(10, 20),
+		WithSizeBuckets(1, 1000),
+	)
+	r, _ := compass.New(compass.WithInterceptors(m))
+	inflight := ""
+	_ = r.Get("/posts/:id", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		inflight = scrape(m)
+		_, _ = rw.Write([]byte("post"))
+	}))
+	_ = r.Post("/posts", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	_ = r.Delete("/posts/:id", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("ohh no!")
+	}))
+
+	for _, target := range []string{"/posts/1", "/posts/2", "/missing"} {
+		req := httptest.NewRequest("GET", "http://example.com"+target, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	for _, method := range []string{"POST", "PURGE", "FOO"} {
+		req := httptest.NewRequest(method, "http://example.com/posts", nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("DELETE", "http://example.com/posts/1", nil)
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, req)
+	if rw.Code != http.StatusInternalServerError {
+		t.Fatalf("want status code 500 for the panic, but got %d", rw.Code)
+	}
+
+	t.Run("tracks in-flight requests", func(t *testing.T) {
+		want := `blog_http_requests_in_flight{method="GET",route="/posts/:id"} 1`
+		if !strings.Contains(inflight, want) {
+			t.Fatalf("must contain %s, got:\n%s", want, inflight)
+		}
+	})
+
+	body := scrape(m)
+	wantLines := []string{
+		"# TYPE blog_http_requests_total counter",
+		`blog_http_requests_total{method="GET",route="/posts/:id",status="2xx"} 2`,
+		`blog_http_requests_total{method="GET",route="notfound",status="4xx"} 1`,
+		`blog_http_requests_total{method="POST",route="/posts",status="2xx"} 1`,
+		`blog_http_requests_total{method="DELETE",route="/posts/:id",status="5xx"} 1`,
+		`blog_http_requests_total{method="other",route="notfound",status="5xx"} 2`,
+		`blog_http_requests_in_flight{method="DELETE",route="/posts/:id"} 0`,
+		"# TYPE blog_http_requests_in_flight gauge",
+		`blog_http_requests_in_flight{method="GET",route="/posts/:id"} 0`,
+		"# TYPE blog_http_request_duration_seconds histogram",
+		`blog_http_request_duration_seconds_bucket{method="GET",route="/posts/:id",status="2xx",le="10"} 2`,
+		`blog_http_request_duration_seconds_bucket{method="GET",route="/posts/:id",status="2xx",le="+Inf"} 2`,
+		`blog_http_request_duration_seconds_count{method="GET",route="/posts/:id",status="2xx"} 2`,
+		"# TYPE blog_http_response_size_bytes histogram",
+		`blog_http_response_size_bytes_bucket{method="GET",route="/posts/:id",status="2xx",le="1"} 0`,
+		`blog_http_response_size_bytes_bucket{method="GET",route="/posts/:id",status="2xx",le="1000"} 2`,
+		`blog_http_response_size_bytes_sum{method="GET",route="/posts/:id",status="2xx"} 8`,
+		`blog_http_response_size_bytes_bucket{method="POST",route="/posts",status="2xx",le="1"} 1`,
+	}
+	for _, line := range wantLines {
+		t.Run("exposes "+line, func(t *testing.T) {
+			if !strings.Contains(body, line+"\n") {
+				t.Fatalf("must contain %s, got:\n%s", line, body)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m, _ := New()
+	rw := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	if rw.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("must serve Prometheus text exposition format")
+	}
+}
+
+func TestRouteLabels(t *testing.T) {
+	rl := routeLabels{method: "GET", route: "/a\"b\\c\n"}
+	want := `method="GET",route="/a\"b\\c\n"`
+	if rl.String() != want {
+		t.Fatalf("want %s, got %s", want, rl.String())
+	}
+}
+
+func scrape(m *Metrics) string {
+	rw := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rw.Result().Body)
+	return string(body)
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package ratelimit provides an interceptor which limits the request rate per
+// key with the generic cell rate algorithm (GCRA), a token bucket variant which
+// keeps a single timestamp per key.
+package ratelimit
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// RateLimiter is an interceptor which rejects the requests over the limit
+// with 429
+type RateLimiter struct {
+	limit   Limit
+	store   Store
+	key     KeyFunc
+	prefix  string
+	handler http.Handler
+	now     func() time.Time
+}
+
+// Option is a rate limiter option
+type Option func(*RateLimiter) error
+
+// KeyFunc returns the rate limiting key of the request
+type KeyFunc func(req *http.Request) string
+
+// Limit allows Rate requests per Period with bursts up to Burst requests
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// Result is the result of taking a token for a request
+type Result struct {
+	// Allowed reports whether the request is under the limit
+	Allowed bool
+
+	// Remaining is the number of the requests which are allowed right now
+	Remaining int
+
+	// Reset is the duration until the bucket is full again
+	Reset time.Duration
+
+	// RetryAfter is the duration until the next request is allowed, it is
+	// zero for the allowed requests
+	RetryAfter time.Duration
+}
+
+// Store keeps the rate limiting state of the keys, the implementations must be
+// safe for concurrent use
+type Store interface {
+	// Take takes a token from the bucket of the key at the given time
+	Take(key string, limit Limit, now time.Time) (Result, error)
+}
+
+const (
+	headerLimit      = "RateLimit-Limit"
+	headerRemaining  = "RateLimit-Remaining"
+	headerReset      = "RateLimit-Reset"
+	headerRetryAfter = "Retry-After"
+)
+
+// New returns a new rate limiter with the limit, the requests are keyed by
+// the client IP and kept in a MemoryStore unless other options are given
+func New(limit Limit, options ...Option) (*RateLimiter, error) {
+	if limit.Rate < 1 || limit.Period <= 0 {
+		return nil, errors.New("limit rate and period must be positive")
+	}
+	if limit.Period/time.Duration(limit.Rate) == 0 {
+		return nil, errors.New("limit period must be at least rate nanoseconds")
+	}
+	if limit.Burst < 0 {
+		return nil, errors.New("limit burst can't be negative")
+	}
+	if limit.Burst == 0 {
+		limit.Burst = limit.Rate
+	}
+
+	rl := &RateLimiter{
+		limit:   limit,
+		store:   NewMemoryStore(),
+		key:     KeyByIP,
+		handler: http.HandlerFunc(tooManyRequests),
+		now:     time.Now,
+	}
+
+	for _, o := range options {
+		if err := o(rl); err != nil {
+			return nil, err
+		}
+	}
+
+	return rl, nil
+}
+
+// WithStore option sets the store of the rate limiting state, a shared store
+// can be used to limit the requests across instances
+func WithStore(s Store) Option {
+	return func(rl *RateLimiter) error {
+		if s == nil {
+			return errors.New("store can't be nil")
+		}
+		rl.store = s
+		return nil
+	}
+}
+
+// WithKey option sets the function which returns the rate limiting key of the
+// requests
+func WithKey(fn KeyFunc) Option {
+	return func(rl *RateLimiter) error {
+		if fn == nil {
+			return errors.New("key func can't be nil")
+		}
+		rl.key = fn
+		return nil
+	}
+}
+
+// WithPrefix option prefixes the keys to let the rate limiters share a store
+func WithPrefix(prefix string) Option {
+	return func(rl *RateLimiter) error {
+		rl.prefix = prefix
+		return nil
+	}
+}
+
+// WithHandler option sets the handler of the rejected requests, the rate
+// limit headers are set before the handler is served
+func WithHandler(h http.Handler) Option {
+	return func(rl *RateLimiter) error {
+		if h == nil {
+			return errors.New("handler can't be nil")
+		}
+		rl.handler = h
+		return nil
+	}
+}
+
+// KeyByIP returns the host of the remote address of the request
+func KeyByIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader returns a KeyFunc which returns the value of the header, the
+// requests without the header share the same key
+func KeyByHeader(header string) KeyFunc {
+	return func(req *http.Request) string {
+		return req.Header.Get(header)
+	}
+}
+
+// KeyByRoute returns the method and the pattern of the matched route, all
+// requests of a route share the same key
+func KeyByRoute(req *http.Request) string {
+	route := compass.Route(req.Context())
+	return route.Method + " " + route.Pattern
+}
+
+// Middleware implements interceptor.Interceptor. The requests are allowed
+// when the store fails to not take the service down with the store.
+func (rl *RateLimiter) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if cinterceptor.IsSubrequest(req.Context()) {
+			h.ServeHTTP(rw, req)
+			return
+		}
+		res, err := rl.store.Take(rl.prefix+rl.key(req), rl.limit, rl.now())
+		if err != nil {
+			h.ServeHTTP(rw, req)
+			return
+		}
+
+		header := rw.Header()
+		header.Set(headerLimit, strconv.Itoa(rl.limit.Burst))
+		header.Set(headerRemaining, strconv.Itoa(res.Remaining))
+		header.Set(headerReset, seconds(res.Reset))
+		if !res.Allowed {
+			header.Set(headerRetryAfter, seconds(res.RetryAfter))
+			rl.handler.ServeHTTP(rw, req)
+			return
+		}
+		h.ServeHTTP(rw, req)
+	})
+}
+
+// Take computes the result of a request at now for the theoretical arrival
+// time of the key and returns the new theoretical arrival time. The stores
+// must only save the new time for the allowed requests. A zero tat is treated
+// as a full bucket.
+func (l Limit) Take(tat, now time.Time) (time.Time, Result) {
+	interval := l.Period / time.Duration(l.Rate)
+	tolerance := interval * time.Duration(l.Burst)
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(interval)
+	allowAt := next.Add(-tolerance)
+	if now.Before(allowAt) {
+		return tat, Result{
+			Remaining:  0,
+			Reset:      tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+
+	return next, Result{
+		Allowed:   true,
+		Remaining: int(now.Sub(allowAt) / interval),
+		Reset:     next.Sub(now),
+	}
+}
+
+func tooManyRequests(rw http.ResponseWriter, req *http.Request) {
+	http.Error(rw,
+		http.StatusText(http.StatusTooManyRequests),
+		http.StatusTooManyRequests,
+	)
+}
+
+// seconds formats the duration as seconds by rounding up
+func seconds(d time.Duration) string {
+	s := d / time.Second
+	if d%time.Second > 0 {
+		s++
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
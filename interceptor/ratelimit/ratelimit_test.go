@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	limit := Limit{Rate: 1, Period: time.Second}
+	tests := []struct {
+		limit  Limit
+		option Option
+		err    string
+	}{
+		{Limit{Period: time.Second}, WithPrefix(""), "limit rate and period must be positive"},
+		{Limit{Rate: 1}, WithPrefix(""), "limit rate and period must be positive"},
+		{Limit{Rate: 2000, Period: time.Microsecond}, WithPrefix(""), "limit period must be at least rate nanoseconds"},
+		{Limit{Rate: 1, Period: time.Second, Burst: -1}, WithPrefix(""), "limit burst can't be negative"},
+		{limit, WithStore(nil), "store can't be nil"},
+		{limit, WithKey(nil), "key func can't be nil"},
+		{limit, WithHandler(nil), "handler can't be nil"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.limit, test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	rl, _ := New(
+		Limit{Rate: 2, Period: time.Second, Burst: 3},
+		WithKey(KeyByHeader("X-API-Key")),
+	)
+	rl.now = func() time.Time { return now }
+	h := rl.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	tests := []struct {
+		key        string
+		elapsed    time.Duration
+		statusCode int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{"a", 0, http.StatusOK, "2", "1", ""},
+		{"a", 0, http.StatusOK, "1", "1", ""},
+		{"a", 0, http.StatusOK, "0", "2", ""},
+		{"a", 0, http.StatusTooManyRequests, "0", "2", "1"},
+		{"b", 0, http.StatusOK, "2", "1", ""},
+		{"a", 500 * time.Millisecond, http.StatusOK, "0", "2", ""},
+		{"a", 0, http.StatusTooManyRequests, "0", "2", "1"},
+	}
+
+	for _, test := range tests {
+		now = now.Add(test.elapsed)
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-API-Key", test.key)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		t.Run("limits requests per key", func(t *testing.T) {
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			want := map[string]string{
+				"RateLimit-Limit":     "3",
+				"RateLimit-Remaining": test.remaining,
+				"RateLimit-Reset":     test.reset,
+				"Retry-After":         test.retryAfter,
+			}
+			for k, v := range want {
+				if got := rw.Header().Get(k); got != v {
+					t.Fatalf("want %s header %q, but got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPerRouteLimits(t *testing.T) {
+	rl, _ := New(
+		Limit{Rate: 1, Period: time.Minute},
+		WithKey(KeyByRoute),
+		WithHandler(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		})),
+	)
+	r, _ := compass.New()
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	_ = r.Post("/login", ok, compass.Interceptors(rl))
+	_ = r.Get("/posts", ok)
+
+	tests := []struct {
+		method     string
+		path       string
+		statusCode int
+	}{
+		{"POST", "/login", http.StatusOK},
+		{"POST", "/login", http.StatusServiceUnavailable},
+		{"GET", "/posts", http.StatusOK},
+		{"GET", "/posts", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest(test.method, "http://example.com"+test.path, nil))
+		t.Run("limits only the attached routes", func(t *testing.T) {
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d for %s, but got %d",
+					test.statusCode, test.path, rw.Code)
+			}
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(string, Limit, time.Time) (Result, error) {
+	return Result{}, errors.New("store is down")
+}
+
+func TestStoreFailure(t *testing.T) {
+	rl, _ := New(Limit{Rate: 1, Period: time.Minute}, WithStore(failingStore{}))
+	h := rl.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/", nil))
+		if rw.Code != http.StatusOK {
+			t.Fatalf("requests must be allowed when the store fails, got %d", rw.Code)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := KeyByIP(req); got != "10.0.0.1" {
+		t.Fatalf("want ip key, got %q", got)
+	}
+	req.RemoteAddr = "10.0.0.1"
+	if got := KeyByIP(req); got != "10.0.0.1" {
+		t.Fatalf("want remote addr key, got %q", got)
+	}
+}
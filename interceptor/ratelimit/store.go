@@ -0,0 +1,64 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store, the keys with full buckets are evicted
+// periodically
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	interval  time.Duration
+	lastSweep time.Time
+}
+
+// DefaultSweepInterval is the default interval of the evictions
+const DefaultSweepInterval = time.Minute
+
+// NewMemoryStore returns a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tats:     make(map[string]time.Time),
+		interval: DefaultSweepInterval,
+	}
+}
+
+// Take implements Store interface
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	tat, res := limit.Take(s.tats[key], now)
+	if res.Allowed {
+		s.tats[key] = tat
+	}
+	return res, nil
+}
+
+// Len returns the number of the keys in the store
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tats)
+}
+
+// sweep evicts the keys whose buckets are full, they are equivalent to the
+// missing keys
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.interval {
+		return
+	}
+	s.lastSweep = now
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+}
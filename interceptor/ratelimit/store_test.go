@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Rate: 1, Period: time.Second, Burst: 1}
+	now := time.Unix(1600000000, 0)
+
+	for _, key := range []string{"a", "b"} {
+		if res, _ := s.Take(key, limit, now); !res.Allowed {
+			t.Fatalf("first request of %s must be allowed", key)
+		}
+	}
+	if res, _ := s.Take("a", limit, now); res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("second request must be rejected for a second, got %+v", res)
+	}
+	if s.Len() != 2 {
+		t.Fatalf("want 2 keys, got %d", s.Len())
+	}
+
+	t.Run("evicts full buckets", func(t *testing.T) {
+		_, _ = s.Take("c", limit, now.Add(DefaultSweepInterval))
+		if s.Len() != 1 {
+			t.Fatalf("want 1 key after eviction, got %d", s.Len())
+		}
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package requestid provides an interceptor which accepts or generates request
+// ids, stores them in the request context and echoes them on the response.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	chandler "github.com/mustafaturan/compass/handler"
+)
+
+// RequestID is an interceptor which propagates request ids
+type RequestID struct {
+	header    string
+	maxLength int
+	generator func() string
+}
+
+// Option is a request id option
+type Option func(*RequestID) error
+
+const (
+	// DefaultHeader is the default request id header
+	DefaultHeader = "X-Request-ID"
+
+	// DefaultMaxLength is the default max length of the incoming request ids
+	DefaultMaxLength = 64
+
+	// crockford is the Crockford's base32 alphabet which keeps the sort order
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+var generator = &monotonic{}
+
+// New returns a new request id interceptor
+func New(options ...Option) (*RequestID, error) {
+	r := &RequestID{
+		header:    DefaultHeader,
+		maxLength: DefaultMaxLength,
+		generator: Generate,
+	}
+
+	for _, o := range options {
+		if err := o(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// WithHeader option sets the header which is used to accept and echo the
+// request ids
+func WithHeader(header string) Option {
+	return func(r *RequestID) error {
+		if header == "" {
+			return errors.New("header can't be empty")
+		}
+		r.header = header
+		return nil
+	}
+}
+
+// WithMaxLength option sets the max length of the incoming request ids, longer
+// ids are replaced with generated ones
+func WithMaxLength(length int) Option {
+	return func(r *RequestID) error {
+		if length < 1 {
+			return errors.New("max length must be positive")
+		}
+		r.maxLength = length
+		return nil
+	}
+}
+
+// WithGenerator option sets the request id generator
+func WithGenerator(fn func() string) Option {
+	return func(r *RequestID) error {
+		if fn == nil {
+			return errors.New("generator can't be nil")
+		}
+		r.generator = fn
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (r *RequestID) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(r.header)
+		if !r.isValid(id) {
+			id = r.generator()
+		}
+
+		rw.Header().Set(r.header, id)
+		h.ServeHTTP(rw, req.WithContext(NewContext(req.Context(), id)))
+	})
+}
+
+// FromContext returns the request id from the context, it is empty when the
+// context has no request id
+func FromContext(ctx context.Context) string {
+	return chandler.RequestID(ctx)
+}
+
+// NewContext returns a copy of the context with the given request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return chandler.WithRequestID(ctx, id)
+}
+
+// Generate returns a new unique id which is lexicographically sortable by its
+// creation time. The ids are 26 chars long and consist of a 48 bit millisecond
+// timestamp and 80 random bits encoded with Crockford's base32.
+func Generate() string {
+	return generator.next(time.Now())
+}
+
+// isValid validates the length and the charset of the incoming request id,
+// only alphanumerics, `-`, `_`, `.` and `:` are accepted
+func (r *RequestID) isValid(id string) bool {
+	if len(id) == 0 || len(id) > r.maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// monotonic generates ids which increment the random part when more than one
+// id is generated within the same millisecond to keep the order
+type monotonic struct {
+	mu      sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}
+
+func (m *monotonic) next(t time.Time) string {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+
+	m.mu.Lock()
+	if ms > m.ms {
+		m.ms = ms
+		if _, err := rand.Read(m.entropy[:]); err != nil {
+			panic(err)
+		}
+	} else {
+		increment(m.entropy[:])
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(m.ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(m.ms))
+	copy(id[6:], m.entropy[:])
+	m.mu.Unlock()
+
+	return encode(id)
+}
+
+func increment(b []byte) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+}
+
+// encode encodes 128 bits into 26 base32 chars, the first char holds the top
+// 3 bits
+func encode(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[0:8])
+	lo := binary.BigEndian.Uint64(id[8:16])
+
+	dst := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		dst[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(dst)
+}
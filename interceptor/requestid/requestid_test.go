@@ -0,0 +1,129 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		option Option
+		err    string
+	}{
+		{WithHeader(""), "header can't be empty"},
+		{WithMaxLength(0), "max length must be positive"},
+		{WithGenerator(nil), "generator can't be nil"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r, _ := New(
+		WithHeader("X-Correlation-ID"),
+		WithMaxLength(8),
+		WithGenerator(func() string { return "generated" }),
+	)
+
+	tests := []struct {
+		incoming string
+		want     string
+	}{
+		{"", "generated"},
+		{"abc-123", "abc-123"},
+		{"a_b.c:d", "a_b.c:d"},
+		{"too-long-id", "generated"},
+		{"bad id", "generated"},
+		{"<script>", "generated"},
+	}
+
+	for _, test := range tests {
+		var got string
+		h := r.Middleware(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			got = FromContext(req.Context())
+		}))
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		if test.incoming != "" {
+			req.Header.Set("X-Correlation-ID", test.incoming)
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		t.Run("stores request id in context", func(t *testing.T) {
+			if got != test.want {
+				t.Fatalf("want %s, got %s", test.want, got)
+			}
+		})
+		t.Run("echoes request id", func(t *testing.T) {
+			if rw.Header().Get("X-Correlation-ID") != test.want {
+				t.Fatalf("want %s, got %s", test.want, rw.Header().Get("X-Correlation-ID"))
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != "" {
+		t.Fatalf("must return empty id without request id")
+	}
+	if FromContext(NewContext(context.Background(), "abc")) != "abc" {
+		t.Fatalf("must return the request id from the context")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("generates sortable unique ids", func(t *testing.T) {
+		prev := ""
+		seen := make(map[string]struct{})
+		for i := 0; i < 1000; i++ {
+			id := Generate()
+			if len(id) != 26 {
+				t.Fatalf("ids must be 26 chars long, got %s", id)
+			}
+			if _, ok := seen[id]; ok {
+				t.Fatalf("ids must be unique, got %s twice", id)
+			}
+			if id <= prev {
+				t.Fatalf("ids must be sortable, got %s after %s", id, prev)
+			}
+			seen[id], prev = struct{}{}, id
+		}
+	})
+
+	t.Run("encodes the timestamp first", func(t *testing.T) {
+		m := &monotonic{}
+		early := m.next(time.Unix(1000, 0))
+		late := m.next(time.Unix(2000, 0))
+		if early[:10] >= late[:10] {
+			t.Fatalf("timestamp part must be sortable: %s, %s", early, late)
+		}
+		if strings.Trim(early, crockford) != "" {
+			t.Fatalf("ids must use Crockford's base32 alphabet: %s", early)
+		}
+	})
+
+	t.Run("increments within the same millisecond", func(t *testing.T) {
+		m := &monotonic{}
+		now := time.Unix(1000, 0)
+		first, second := m.next(now), m.next(now)
+		if first[:10] != second[:10] || first >= second {
+			t.Fatalf("ids must increase within a millisecond: %s, %s", first, second)
+		}
+	})
+}
+
+func TestIncrement(t *testing.T) {
+	b := []byte{0x00, 0xff, 0xff}
+	increment(b)
+	if b[0] != 0x01 || b[1] != 0x00 || b[2] != 0x00 {
+		t.Fatalf("increment must carry over, got %v", b)
+	}
+}
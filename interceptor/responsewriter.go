@@ -0,0 +1,160 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package interceptor
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter is a http.ResponseWriter which keeps track of the response
+// status code and the number of written body bytes
+type ResponseWriter interface {
+	http.ResponseWriter
+
+	// Status returns the response status code, it is 0 until the header is
+	// written
+	Status() int
+
+	// BytesWritten returns the number of written body bytes
+	BytesWritten() int64
+
+	// Written reports whether the header is written
+	Written() bool
+
+	// Unwrap returns the underlying http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int64
+}
+
+// flushWriter preserves http.Flusher
+type flushWriter struct {
+	*responseWriter
+}
+
+// http1Writer preserves http.Flusher, http.Hijacker and io.ReaderFrom
+type http1Writer struct {
+	*responseWriter
+}
+
+// http2Writer preserves http.Flusher and http.Pusher
+type http2Writer struct {
+	*responseWriter
+}
+
+// WrapResponseWriter wraps the given http.ResponseWriter to track the status
+// code and the written bytes. The optional http.Flusher, http.Hijacker,
+// http.Pusher and io.ReaderFrom interfaces of the underlying writer are
+// preserved. An already wrapped writer is returned as is.
+func WrapResponseWriter(rw http.ResponseWriter) ResponseWriter {
+	if w, ok := rw.(ResponseWriter); ok {
+		return w
+	}
+
+	w := &responseWriter{ResponseWriter: rw}
+	_, isFlusher := rw.(http.Flusher)
+	_, isHijacker := rw.(http.Hijacker)
+	_, isPusher := rw.(http.Pusher)
+	_, isReaderFrom := rw.(io.ReaderFrom)
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return http1Writer{w}
+	case isFlusher && isPusher:
+		return http2Writer{w}
+	case isFlusher:
+		return flushWriter{w}
+	}
+	return w
+}
+
+// WriteHeader writes the header with the status code once
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes the body bytes
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Status returns the response status code
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// BytesWritten returns the number of written body bytes
+func (w *responseWriter) BytesWritten() int64 {
+	return w.bytes
+}
+
+// Written reports whether the header is written
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Unwrap returns the underlying http.ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher
+func (w flushWriter) Flush() {
+	flush(w.responseWriter)
+}
+
+// Flush implements http.Flusher
+func (w http1Writer) Flush() {
+	flush(w.responseWriter)
+}
+
+// Hijack implements http.Hijacker
+func (w http1Writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// ReadFrom implements io.ReaderFrom
+func (w http1Writer) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	w.bytes += n
+	return n, err
+}
+
+// Flush implements http.Flusher
+func (w http2Writer) Flush() {
+	flush(w.responseWriter)
+}
+
+// Push implements http.Pusher
+func (w http2Writer) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+func flush(w *responseWriter) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
@@ -0,0 +1,102 @@
+package interceptor
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrapResponseWriter(t *testing.T) {
+	t.Run("tracks status and bytes", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rw := WrapResponseWriter(rec)
+		if rw.Written() || rw.Status() != 0 {
+			t.Fatalf("header must not be written initially")
+		}
+
+		_, _ = rw.Write([]byte("hello"))
+		rw.WriteHeader(http.StatusTeapot)
+		_, _ = rw.Write([]byte(" world"))
+
+		if rw.Status() != http.StatusOK || rec.Code != http.StatusOK {
+			t.Fatalf("implicit status must be 200, got %d", rw.Status())
+		}
+		if rw.BytesWritten() != 11 || !rw.Written() {
+			t.Fatalf("want 11 bytes written, got %d", rw.BytesWritten())
+		}
+		if rw.Unwrap() != rec {
+			t.Fatalf("must unwrap the underlying writer")
+		}
+		if WrapResponseWriter(rw) != rw {
+			t.Fatalf("must not wrap an already wrapped writer")
+		}
+	})
+
+	t.Run("preserves optional interfaces", func(t *testing.T) {
+		tests := []struct {
+			rw       http.ResponseWriter
+			flusher  bool
+			hijacker bool
+			pusher   bool
+		}{
+			{rw: struct{ http.ResponseWriter }{httptest.NewRecorder()}},
+			{rw: httptest.NewRecorder(), flusher: true},
+			{rw: &http1Recorder{httptest.NewRecorder()}, flusher: true, hijacker: true},
+			{rw: &http2Recorder{httptest.NewRecorder()}, flusher: true, pusher: true},
+		}
+
+		for _, test := range tests {
+			rw := WrapResponseWriter(test.rw)
+			if _, ok := rw.(http.Flusher); ok != test.flusher {
+				t.Fatalf("flusher must be %v for %T", test.flusher, test.rw)
+			}
+			if _, ok := rw.(http.Hijacker); ok != test.hijacker {
+				t.Fatalf("hijacker must be %v for %T", test.hijacker, test.rw)
+			}
+			if _, ok := rw.(http.Pusher); ok != test.pusher {
+				t.Fatalf("pusher must be %v for %T", test.pusher, test.rw)
+			}
+		}
+	})
+
+	t.Run("flush writes the header", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rw := WrapResponseWriter(rec)
+		rw.(http.Flusher).Flush()
+		if !rec.Flushed || rw.Status() != http.StatusOK {
+			t.Fatalf("flush must be delegated with the default status")
+		}
+	})
+
+	t.Run("read from tracks bytes", func(t *testing.T) {
+		rw := WrapResponseWriter(&http1Recorder{httptest.NewRecorder()})
+		written, _ := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("pong"))
+		if written != 4 || rw.BytesWritten() != 4 {
+			t.Fatalf("want 4 bytes written, got %d", rw.BytesWritten())
+		}
+	})
+}
+
+type http1Recorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *http1Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func (r *http1Recorder) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+type http2Recorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *http2Recorder) Push(target string, opts *http.PushOptions) error {
+	return nil
+}
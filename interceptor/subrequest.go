@@ -0,0 +1,28 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package interceptor
+
+import (
+	"context"
+)
+
+type ctxKey int8
+
+const ctxSubrequest = ctxKey(0)
+
+// WithSubrequest returns a copy of the context which marks the request as an
+// internal subrequest of an interceptor like the GET subrequests of the etag
+// resolver. The interceptors which limit or record the client requests skip
+// the subrequests.
+func WithSubrequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxSubrequest, true)
+}
+
+// IsSubrequest reports whether the request of the context is an internal
+// subrequest
+func IsSubrequest(ctx context.Context) bool {
+	subrequest, _ := ctx.Value(ctxSubrequest).(bool)
+	return subrequest
+}
@@ -0,0 +1,16 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubrequest(t *testing.T) {
+	ctx := context.Background()
+	if IsSubrequest(ctx) {
+		t.Fatalf("requests must not be subrequests by default")
+	}
+	if !IsSubrequest(WithSubrequest(ctx)) {
+		t.Fatalf("marked requests must be subrequests")
+	}
+}
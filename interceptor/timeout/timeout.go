@@ -0,0 +1,247 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package timeout provides an interceptor which sets request deadlines
+// globally or per route and responds with a configurable handler when the
+// deadline is exceeded.
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// Timeout is an interceptor which cancels the request contexts after the
+// timeout. The responses are buffered by default like http.TimeoutHandler so
+// the timeout response can replace them.
+type Timeout struct {
+	timeout   time.Duration
+	streaming bool
+	handler   http.Handler
+}
+
+// Option is a timeout option
+type Option func(*Timeout) error
+
+// settings are the route settings stored under the settingsKey
+type settings struct {
+	timeout   time.Duration
+	streaming bool
+}
+
+// timeoutWriter buffers the response until the handler returns
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const settingsKey = settingKey(0)
+
+// New returns a new timeout interceptor with the default timeout, the timed
+// out requests respond with 503 unless another handler is given
+func New(timeout time.Duration, options ...Option) (*Timeout, error) {
+	if timeout <= 0 {
+		return nil, errors.New("timeout must be positive")
+	}
+	t := &Timeout{
+		timeout: timeout,
+		handler: chandler.Status{Code: http.StatusServiceUnavailable},
+	}
+
+	for _, o := range options {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// WithHandler option sets the handler of the timed out requests, like a
+// handler responding with 504
+func WithHandler(h http.Handler) Option {
+	return func(t *Timeout) error {
+		if h == nil {
+			return errors.New("handler can't be nil")
+		}
+		t.handler = h
+		return nil
+	}
+}
+
+// WithStreaming option disables the response buffering for all routes, see
+// Streaming route option for the caveats
+func WithStreaming() Option {
+	return func(t *Timeout) error {
+		t.streaming = true
+		return nil
+	}
+}
+
+// For route option overrides the default timeout of the route
+func For(timeout time.Duration) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		settingsOf(h).timeout = timeout
+		return nil
+	}
+}
+
+// Streaming route option writes the responses of the route directly instead
+// of buffering them. The handlers must stop on the context cancellation since
+// they can't be interrupted, and the timeout handler is only served when the
+// handler hasn't written anything before the deadline.
+func Streaming() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		settingsOf(h).streaming = true
+		return nil
+	}
+}
+
+// Middleware implements interceptor.Interceptor
+func (t *Timeout) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		timeout, streaming := t.timeout, t.streaming
+		if s, ok := compass.Route(req.Context()).Setting(settingsKey).(*settings); ok {
+			if s.timeout > 0 {
+				timeout = s.timeout
+			}
+			streaming = streaming || s.streaming
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+
+		if streaming {
+			t.stream(h, rw, req, timeout)
+			return
+		}
+		t.buffer(h, rw, req, timeout)
+	})
+}
+
+// stream serves the handler directly and the timeout handler only when the
+// handler returns without writing after the deadline
+func (t *Timeout) stream(
+	h http.Handler,
+	rw http.ResponseWriter,
+	req *http.Request,
+	timeout time.Duration,
+) {
+	w := cinterceptor.WrapResponseWriter(rw)
+	h.ServeHTTP(w, req)
+	if req.Context().Err() == context.DeadlineExceeded && !w.Written() {
+		t.timedOut(w, req, timeout)
+	}
+}
+
+// buffer serves the handler in a goroutine with a buffered writer, the
+// buffered response is discarded when the deadline is exceeded first. The
+// panics of the handler are re-panicked to let the router recover them.
+func (t *Timeout) buffer(
+	h http.Handler,
+	rw http.ResponseWriter,
+	req *http.Request,
+	timeout time.Duration,
+) {
+	w := &timeoutWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		h.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		dst := rw.Header()
+		for k, v := range w.header {
+			dst[k] = v
+		}
+		if w.code == 0 {
+			w.code = http.StatusOK
+		}
+		rw.WriteHeader(w.code)
+		_, _ = rw.Write(w.buf.Bytes())
+	case <-req.Context().Done():
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.timedOut = true
+		t.timedOut(rw, req, timeout)
+	}
+}
+
+// timedOut serves the timeout handler with the reason
+func (t *Timeout) timedOut(
+	rw http.ResponseWriter,
+	req *http.Request,
+	timeout time.Duration,
+) {
+	reason := "request timed out after " + timeout.String()
+	t.handler.ServeHTTP(rw, req.WithContext(chandler.WithReason(req.Context(), reason)))
+}
+
+// Header implements http.ResponseWriter interface
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// Write implements http.ResponseWriter interface, it returns
+// http.ErrHandlerTimeout after the deadline
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter interface
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.code != 0 {
+		return
+	}
+	w.code = code
+}
+
+func settingsOf(h *chandler.Handler) *settings {
+	s, ok := h.Setting(settingsKey).(*settings)
+	if !ok {
+		s = &settings{}
+		h.SetSetting(settingsKey, s)
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		option  Option
+		err     string
+	}{
+		{0, WithStreaming(), "timeout must be positive"},
+		{time.Second, WithHandler(nil), "handler can't be nil"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.timeout, test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+
+	r, _ := compass.New()
+	if err := r.Get("/", http.NotFoundHandler(), For(0)); err == nil {
+		t.Fatalf("route timeout must be positive")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	sleep := func(d time.Duration) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Handler", "sleep")
+			select {
+			case <-time.After(d):
+				rw.WriteHeader(http.StatusCreated)
+				_, _ = rw.Write([]byte("done"))
+			case <-req.Context().Done():
+			}
+		})
+	}
+	gatewayTimeout := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, compass.Reason(req.Context()), http.StatusGatewayTimeout)
+	})
+
+	tm, _ := New(20*time.Millisecond, WithHandler(gatewayTimeout))
+	r, _ := compass.New(compass.WithInterceptors(tm))
+	_ = r.Get("/fast", sleep(0))
+	_ = r.Get("/slow", sleep(time.Second))
+	_ = r.Get("/export", sleep(40*time.Millisecond), For(time.Second), compass.Metadata("timeout", "5m"))
+	_ = r.Get("/stream", sleep(time.Second), Streaming())
+
+	tests := []struct {
+		path       string
+		statusCode int
+		body       string
+		header     string
+	}{
+		{"/fast", http.StatusCreated, "done", "sleep"},
+		{"/slow", http.StatusGatewayTimeout, "request timed out after 20ms\n", ""},
+		{"/export", http.StatusCreated, "done", "sleep"},
+		{"/stream", http.StatusGatewayTimeout, "request timed out after 20ms\n", "sleep"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+			if got := rw.Header().Get("X-Handler"); got != test.header {
+				t.Fatalf("want X-Handler header %q, but got %q", test.header, got)
+			}
+		})
+	}
+}
+
+func TestPanics(t *testing.T) {
+	tm, _ := New(time.Second)
+	r, _ := compass.New(compass.WithInterceptors(tm))
+	_ = r.Get("/panic", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("failed")
+	}))
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest("GET", "http://example.com/panic", nil))
+	if rw.Code != http.StatusInternalServerError {
+		t.Fatalf("handler panics must be recovered by the router, got %d", rw.Code)
+	}
+}
+
+func TestTimeoutWriter(t *testing.T) {
+	w := &timeoutWriter{header: make(http.Header)}
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+	if w.code != http.StatusAccepted || w.buf.String() != "ok" {
+		t.Fatalf("want buffered 202 response, got %d %q", w.code, w.buf.String())
+	}
+
+	w.timedOut = true
+	if _, err := w.Write([]byte("late")); err != http.ErrHandlerTimeout {
+		t.Fatalf("want ErrHandlerTimeout, got %v", err)
+	}
+}
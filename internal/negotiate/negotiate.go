@@ -0,0 +1,160 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package negotiate parses Accept headers and selects the best media type for
+// the responses.
+package negotiate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Range is a media range of an Accept header with its quality value
+type Range struct {
+	Type    string
+	Subtype string
+	Q       float64
+}
+
+const wildcard = "*"
+
+// specificities of the media ranges
+const (
+	matchAll = iota
+	matchType
+	matchExact
+)
+
+// ParseAccept parses the media ranges of an Accept header, the ranges are
+// sorted by their quality values and specificities in descending order.
+// The malformed ranges are skipped.
+func ParseAccept(header string) []Range {
+	ranges := make([]Range, 0)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := split(params[0])
+		if !ok {
+			continue
+		}
+		r := Range{Type: typ, Subtype: subtype, Q: 1}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) < 2 || strings.ToLower(param[:2]) != "q=" {
+				continue
+			}
+			q, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			r.Q = q
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Q != ranges[j].Q {
+			return ranges[i].Q > ranges[j].Q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// Best returns the offer which is preferred most by the Accept header, the
+// earlier offers win the ties. It returns the first offer for an empty header
+// and an empty string when none of the offers are acceptable.
+func Best(header string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := ParseAccept(header)
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		q, specificity := quality(ranges, offer)
+		if q > bestQ || (q == bestQ && q > 0 && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// Accepts reports whether the Accept header accepts the media type, an empty
+// header accepts all media types
+func Accepts(header, mediaType string) bool {
+	return Best(header, mediaType) != ""
+}
+
+// Matches reports whether the media range like `image/*` matches the media
+// type
+func Matches(mediaRange, mediaType string) bool {
+	typ, subtype, ok := split(mediaRange)
+	return ok && Range{Type: typ, Subtype: subtype}.Match(mediaType)
+}
+
+// Match reports whether the media range matches the media type
+func (r Range) Match(mediaType string) bool {
+	typ, subtype, ok := split(mediaType)
+	if !ok {
+		return false
+	}
+	switch r.specificity() {
+	case matchAll:
+		return true
+	case matchType:
+		return r.Type == typ
+	default:
+		return r.Type == typ && r.Subtype == subtype
+	}
+}
+
+// MediaType returns the media type without its params in lower case
+func MediaType(contentType string) string {
+	typ, subtype, ok := split(strings.Split(contentType, ";")[0])
+	if !ok {
+		return ""
+	}
+	return typ + "/" + subtype
+}
+
+// quality returns the quality value of the most specific range which matches
+// the media type with the specificity of the range
+func quality(ranges []Range, mediaType string) (float64, int) {
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		if r.Match(mediaType) && r.specificity() > specificity {
+			q, specificity = r.Q, r.specificity()
+		}
+	}
+	return q, specificity
+}
+
+func (r Range) specificity() int {
+	switch {
+	case r.Type == wildcard:
+		return matchAll
+	case r.Subtype == wildcard:
+		return matchType
+	default:
+		return matchExact
+	}
+}
+
+func split(mediaType string) (string, string, bool) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	i := strings.IndexByte(mediaType, '/')
+	if i <= 0 || i == len(mediaType)-1 {
+		return "", "", false
+	}
+	typ, subtype := mediaType[:i], mediaType[i+1:]
+	if typ == wildcard && subtype != wildcard {
+		return "", "", false
+	}
+	return typ, subtype, true
+}
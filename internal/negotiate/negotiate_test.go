@@ -0,0 +1,104 @@
+package negotiate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []Range
+	}{
+		{"", []Range{}},
+		{"text/html", []Range{{"text", "html", 1}}},
+		{
+			"text/*;q=0.5, application/JSON, */*;q=0.1",
+			[]Range{{"application", "json", 1}, {"text", "*", 0.5}, {"*", "*", 0.1}},
+		},
+		{
+			"*/*, text/*, text/plain",
+			[]Range{{"text", "plain", 1}, {"text", "*", 1}, {"*", "*", 1}},
+		},
+		{"text/plain;q=2, text, */json", []Range{{"text", "plain", 0}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			got := ParseAccept(test.header)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestBest(t *testing.T) {
+	offers := []string{"application/problem+json", "text/html", "text/plain"}
+	tests := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"", offers, "application/problem+json"},
+		{"*/*", offers, "application/problem+json"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", offers, "text/html"},
+		{"text/*", offers, "text/html"},
+		{"text/plain, text/html;q=0.9", offers, "text/plain"},
+		{"application/*;q=0.2, text/plain;q=0.5", offers, "text/plain"},
+		{"image/png", offers, ""},
+		{"text/plain;q=0", offers[2:], ""},
+		{"text/plain", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			if got := Best(test.header, test.offers...); got != test.want {
+				t.Fatalf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	if !Accepts("", "text/plain") || !Accepts("text/*", "text/plain") {
+		t.Fatalf("media type must be accepted")
+	}
+	if Accepts("application/json", "text/plain") {
+		t.Fatalf("media type must not be accepted")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		mediaRange string
+		mediaType  string
+		want       bool
+	}{
+		{"image/*", "image/png", true},
+		{"*/*", "text/plain", true},
+		{"application/json", "Application/JSON", true},
+		{"application/json", "application/xml", false},
+		{"image/*", "", false},
+		{"image", "image/png", false},
+	}
+	for _, test := range tests {
+		if got := Matches(test.mediaRange, test.mediaType); got != test.want {
+			t.Fatalf("want %v for %q and %q", test.want, test.mediaRange, test.mediaType)
+		}
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	tests := map[string]string{
+		"Application/JSON; charset=utf-8": "application/json",
+		"text/plain":                      "text/plain",
+		"text":                            "",
+		"":                                "",
+	}
+	for contentType, want := range tests {
+		if got := MediaType(contentType); got != want {
+			t.Fatalf("want %q for %q, got %q", want, contentType, got)
+		}
+	}
+}
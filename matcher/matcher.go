@@ -7,8 +7,10 @@ package matcher
 import (
 	"errors"
 	"net/http"
+	"sort"
 
 	chandler "github.com/mustafaturan/compass/handler"
+	"github.com/mustafaturan/compass/internal/negotiate"
 )
 
 const (
@@ -21,11 +23,30 @@ type Matcher struct {
 }
 
 type node struct {
-	handler *chandler.Handler
+	// handlers are the handlers of the path in the registration order, they
+	// are distinguished by their media types
+	handlers []*chandler.Handler
 
 	nodes map[string]*node
 }
 
+var (
+	// ErrNotFound is returned when no handler is registered for the path
+	ErrNotFound = errors.New("no handler is registered for the path")
+
+	// ErrNoMatch is returned when none of the handlers of the path have
+	// predicates which hold for the request
+	ErrNoMatch = errors.New("no handler matches the request predicates")
+
+	// ErrUnsupportedMediaType is returned when none of the handlers of the
+	// path consume the content type of the request
+	ErrUnsupportedMediaType = errors.New("content type is not supported")
+
+	// ErrNotAcceptable is returned when none of the handlers of the path
+	// produce a media type which is accepted by the request
+	ErrNotAcceptable = errors.New("accepted media types are not produced")
+)
+
 // New inits a new matcher
 func New() *Matcher {
 	return &Matcher{nodes: map[string]*node{
@@ -43,34 +64,102 @@ func New() *Matcher {
 
 // Find finds the top priority HTTP handler
 func (m *Matcher) Find(method string, segments []string) (*chandler.Handler, bool) {
+	if _, ok := m.nodes[method]; !ok {
+		return nil, false
+	}
 	if len(segments) == 0 {
-		return m.nodes[method].handler, m.nodes[method].handler != nil
+		segments = []string{""}
 	}
 	var pn node
 	m.nodes[method].search(segments, 0, &pn)
+	if len(pn.handlers) == 0 {
+		return nil, false
+	}
+	return pn.handlers[0], true
+}
+
+// Match finds the handler of the request among the handlers of the path. The
+// handlers whose predicates hold and which consume the Content-Type of the
+// request are selected in the registration order, then the one which produces
+// the media type preferred most by the Accept header is returned. The first
+// selected handler without produced media types is returned only when none
+// of the produced media types are accepted.
+func (m *Matcher) Match(req *http.Request, segments []string) (*chandler.Handler, error) {
+	if _, ok := m.nodes[req.Method]; !ok {
+		return nil, ErrNotFound
+	}
+	if len(segments) == 0 {
+		segments = []string{""}
+	}
+	var pn node
+	m.nodes[req.Method].search(segments, 0, &pn)
+	if len(pn.handlers) == 0 {
+		return nil, ErrNotFound
+	}
+
+	candidates := make([]*chandler.Handler, 0, len(pn.handlers))
+	for _, h := range pn.handlers {
+		if h.Matches(req) {
+			candidates = append(candidates, h)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	candidates = consumers(candidates, req)
+	if len(candidates) == 0 {
+		return nil, ErrUnsupportedMediaType
+	}
+	h := producer(candidates, req.Header.Get("Accept"))
+	if h == nil {
+		return nil, ErrNotAcceptable
+	}
+	return h, nil
+}
+
+// Methods returns the sorted methods which have a handler for the segments
+func (m *Matcher) Methods(segments []string) []string {
+	methods := make([]string, 0)
+	for method := range m.nodes {
+		if _, found := m.Find(method, segments); found {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
 
-	return pn.handler, pn.handler != nil
+// Supports reports whether the method can have handlers
+func (m *Matcher) Supports(method string) bool {
+	_, ok := m.nodes[method]
+	return ok
 }
 
 // Register adds a new handler for the given path
 func (m *Matcher) Register(method string, h *chandler.Handler) error {
+	if _, ok := m.nodes[method]; !ok {
+		return errors.New("method is not supported")
+	}
 	n := m.nodes[method].insert(h.Segments(), 0)
-	if n.handler != nil {
-		return errors.New("path is already registered for another handler")
+	for _, registered := range n.handlers {
+		if !isDistinguishable(registered, h) {
+			return errors.New("path is already registered for another handler")
+		}
 	}
-	n.handler = h
+	n.handlers = append(n.handlers, h)
 	return nil
 }
 
 func (n *node) search(segments []string, index int, pn *node) {
-	if pn.handler != nil {
+	if len(pn.handlers) > 0 {
 		return
 	}
 	if n == nil {
 		return
 	}
 	if len(segments) == index {
-		pn.handler = n.handler
+		pn.handlers = n.handlers
 		return
 	}
 
@@ -93,7 +182,7 @@ func (n *node) insert(segments []string, index int) *node {
 	}
 	if segment != pathvar &&
 		n.nodes[pathvar] != nil &&
-		n.nodes[pathvar].handler != nil &&
+		len(n.nodes[pathvar].handlers) > 0 &&
 		len(segments) == index+1 {
 		return n.nodes[pathvar]
 	}
@@ -102,3 +191,85 @@ func (n *node) insert(segments []string, index int) *node {
 	n.nodes[segment] = next
 	return next.insert(segments, index+1)
 }
+
+// consumers returns the handlers which consume the content type of the
+// request body, all handlers are returned for the requests without body
+func consumers(handlers []*chandler.Handler, req *http.Request) []*chandler.Handler {
+	if req.ContentLength <= 0 && len(req.TransferEncoding) == 0 {
+		return handlers
+	}
+	mediaType := negotiate.MediaType(req.Header.Get("Content-Type"))
+	candidates := make([]*chandler.Handler, 0, len(handlers))
+	for _, h := range handlers {
+		if len(h.Consumes) == 0 || matchesAny(h.Consumes, mediaType) {
+			candidates = append(candidates, h)
+		}
+	}
+	return candidates
+}
+
+// producer returns the handler of the best media type for the Accept header,
+// the handlers without media types are only returned when none of the media
+// types are accepted
+func producer(handlers []*chandler.Handler, accept string) *chandler.Handler {
+	offers := make([]string, 0)
+	owners := make(map[string]*chandler.Handler)
+	var fallback *chandler.Handler
+	for _, h := range handlers {
+		if len(h.Produces) == 0 && fallback == nil {
+			fallback = h
+		}
+		for _, mediaType := range h.Produces {
+			if _, ok := owners[mediaType]; !ok {
+				offers = append(offers, mediaType)
+				owners[mediaType] = h
+			}
+		}
+	}
+
+	if best := negotiate.Best(accept, offers...); best != "" {
+		return owners[best]
+	}
+	return fallback
+}
+
+// isDistinguishable reports whether the handlers of the same path can be
+// told apart by their predicates or media types
+func isDistinguishable(a, b *chandler.Handler) bool {
+	return !sameSet(predicateNames(a), predicateNames(b)) ||
+		!sameSet(a.Consumes, b.Consumes) ||
+		!sameSet(a.Produces, b.Produces)
+}
+
+func predicateNames(h *chandler.Handler) []string {
+	names := make([]string, len(h.Predicates))
+	for i, p := range h.Predicates {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func matchesAny(mediaRanges []string, mediaType string) bool {
+	for _, mediaRange := range mediaRanges {
+		if negotiate.Matches(mediaRange, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]struct{}, len(a))
+	for _, v := range a {
+		set[v] = struct{}{}
+	}
+	for _, v := range b {
+		if _, ok := set[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
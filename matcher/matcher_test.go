@@ -2,6 +2,7 @@ package matcher
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	chandler "github.com/mustafaturan/compass/handler"
@@ -93,6 +94,13 @@ func TestRegister(t *testing.T) {
 		}
 	})
 
+	t.Run("registration for unsupported method should return error", func(t *testing.T) {
+		handler, _ := chandler.New("/posts", testHTTPHandler{})
+		if err := m.Register("FETCH", handler); err == nil {
+			t.Fatalf("Register(FETCH, %+v) SHOULD return err", handler)
+		}
+	})
+
 	t.Run("registration of the same path should return error", func(t *testing.T) {
 		handler, _ := chandler.New("/posts/:id/reviews/9", testHTTPHandler{})
 		if err := m.Register(http.MethodGet, handler); err == nil {
@@ -186,6 +194,7 @@ func TestFind(t *testing.T) {
 		{http.MethodGet, []string{"reviews"}, nil, false},
 		{http.MethodGet, []string{"reviews", "33"}, nil, false},
 		{http.MethodGet, []string{"posts", "99", "reviews", "56", "likers"}, nil, false},
+		{"FETCH", []string{"posts"}, nil, false},
 	}
 
 	for _, test := range tests {
@@ -221,3 +230,156 @@ type testHTTPHandler struct{}
 
 func (h testHTTPHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 }
+
+func TestMethods(t *testing.T) {
+	m := New()
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		h, _ := chandler.New("/posts/:id", http.NotFoundHandler())
+		_ = m.Register(method, h)
+	}
+
+	tests := []struct {
+		segments []string
+		want     string
+	}{
+		{[]string{"posts", "1"}, "GET,POST"},
+		{[]string{"posts"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run("returns the sorted methods of the path", func(t *testing.T) {
+			if got := strings.Join(m.Methods(test.segments), ","); got != test.want {
+				t.Fatalf("want methods %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	t.Run("supports registrable methods", func(t *testing.T) {
+		if !m.Supports(http.MethodGet) || m.Supports("PROPFIND") {
+			t.Fatalf("must support only registrable methods")
+		}
+	})
+}
+
+func TestMatch(t *testing.T) {
+	newHandler := func(consumes, produces []string) *chandler.Handler {
+		h, _ := chandler.New("/reports/:id", testHTTPHandler{})
+		h.Consumes, h.Produces = consumes, produces
+		return h
+	}
+	jsonType, csvType := "application/json", "text/csv"
+	jsonReport := newHandler(nil, []string{jsonType})
+	csvReport := newHandler(nil, []string{csvType})
+	jsonBody := newHandler([]string{jsonType}, nil)
+	protoBody := newHandler([]string{"application/protobuf"}, nil)
+
+	m := New()
+	for _, reg := range []struct {
+		method string
+		h      *chandler.Handler
+	}{
+		{http.MethodGet, jsonReport},
+		{http.MethodGet, csvReport},
+		{http.MethodPost, jsonBody},
+		{http.MethodPost, protoBody},
+	} {
+		if err := m.Register(reg.method, reg.h); err != nil {
+			t.Fatalf("handlers with different media types must be registered, got %s", err)
+		}
+	}
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		accept      string
+		want        *chandler.Handler
+		err         error
+	}{
+		{"GET", "/reports/1", "", "", jsonReport, nil},
+		{"GET", "/reports/1", "", "text/csv", csvReport, nil},
+		{"GET", "/reports/1", "", "application/json;q=0.5, text/*", csvReport, nil},
+		{"GET", "/reports/1", "", "text/html", nil, ErrNotAcceptable},
+		{"POST", "/reports/1", jsonType, "", jsonBody, nil},
+		{"POST", "/reports/1", "application/protobuf", "", protoBody, nil},
+		{"POST", "/reports/1", "text/plain", "", nil, ErrUnsupportedMediaType},
+		{"GET", "/posts/1", "", "", nil, ErrNotFound},
+		{"FETCH", "/reports/1", "", "", nil, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.contentType+" "+test.accept, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, "http://example.com"+test.path,
+				strings.NewReader("{}"))
+			if test.method == "GET" {
+				req.Body, req.ContentLength = http.NoBody, 0
+			}
+			req.Header.Set("Content-Type", test.contentType)
+			req.Header.Set("Accept", test.accept)
+
+			h, err := m.Match(req, strings.Split(test.path[1:], "/"))
+			if h != test.want || err != test.err {
+				t.Fatalf("want handler %p with err(%v), got %p with err(%v)",
+					test.want, test.err, h, err)
+			}
+		})
+	}
+
+	t.Run("falls back to the handlers without media types", func(t *testing.T) {
+		generic := newHandler(nil, nil)
+		if err := m.Register(http.MethodGet, generic); err != nil {
+			t.Fatalf("handler without media types must be registered, got %s", err)
+		}
+		req, _ := http.NewRequest("GET", "http://example.com/reports/1", nil)
+		req.Header.Set("Accept", "text/html")
+		if h, _ := m.Match(req, []string{"reports", "1"}); h != generic {
+			t.Fatalf("want handler without media types")
+		}
+	})
+
+	t.Run("rejects indistinguishable handlers", func(t *testing.T) {
+		h := newHandler(nil, []string{csvType})
+		if err := m.Register(http.MethodGet, h); err == nil {
+			t.Fatalf("handlers with the same media types must be rejected")
+		}
+	})
+}
+
+func TestMatchPredicates(t *testing.T) {
+	newHandler := func(header string) *chandler.Handler {
+		h, _ := chandler.New("/items", testHTTPHandler{})
+		h.Predicates = []chandler.Predicate{{
+			Name:  "header " + header,
+			Match: func(req *http.Request) bool { return req.Header.Get(header) != "" },
+		}}
+		return h
+	}
+	first, second := newHandler("X-First"), newHandler("X-Second")
+	m := New()
+	_ = m.Register(http.MethodGet, first)
+	_ = m.Register(http.MethodGet, second)
+
+	tests := []struct {
+		headers []string
+		want    *chandler.Handler
+		err     error
+	}{
+		{[]string{"X-First", "X-Second"}, first, nil},
+		{[]string{"X-Second"}, second, nil},
+		{nil, nil, ErrNoMatch},
+	}
+
+	for _, test := range tests {
+		t.Run("evaluates predicates in registration order", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com/items", nil)
+			for _, h := range test.headers {
+				req.Header.Set(h, "1")
+			}
+			h, err := m.Match(req, []string{"items"})
+			if h != test.want || err != test.err {
+				t.Fatalf("want handler %p with err(%v), got %p with err(%v)",
+					test.want, test.err, h, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package openapi generates OpenAPI 3.0 documents from the compass routes
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+)
+
+// Info is the metadata about the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI string               `json:"openapi"`
+	Info    Info                 `json:"info"`
+	Paths   map[string]*PathItem `json:"paths"`
+}
+
+// PathItem holds the operations of a path keyed by lowercase method names
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation on a path
+type Operation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []Parameter                `json:"parameters,omitempty"`
+	RequestBody *RequestBodyObject         `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+}
+
+// Parameter describes a single operation parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBodyObject describes a request body
+type RequestBodyObject struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// ResponseObject describes a single response of an operation
+type ResponseObject struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a media type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// operation is the route setting stored under the operationKey
+type operation struct {
+	summary     string
+	description string
+	tags        []string
+	requestBody *Schema
+	responses   map[int]*ResponseObject
+	deprecated  bool
+	hidden      bool
+}
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const (
+	version         = "3.0.3"
+	operationKey    = settingKey(0)
+	jsonContentType = "application/json"
+	pathvar         = ':'
+)
+
+// Summary option sets the operation summary of the route
+func Summary(summary string) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		operationOf(h).summary = summary
+		return nil
+	}
+}
+
+// Description option sets the operation description of the route
+func Description(description string) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		operationOf(h).description = description
+		return nil
+	}
+}
+
+// Tags option appends tags to the operation of the route
+func Tags(tags ...string) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		op := operationOf(h)
+		op.tags = append(op.tags, tags...)
+		return nil
+	}
+}
+
+// RequestBody option sets the JSON request body schema of the route
+func RequestBody(schema *Schema) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		if schema == nil {
+			return errors.New("request body schema can't be nil")
+		}
+		operationOf(h).requestBody = schema
+		return nil
+	}
+}
+
+// Response option adds a JSON response schema for the status code of the
+// route, the schema can be nil for the responses without body
+func Response(statusCode int, description string, schema *Schema) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		if statusCode < 100 || statusCode > 599 {
+			return errors.New("response status code is invalid")
+		}
+		res := &ResponseObject{Description: description}
+		if schema != nil {
+			res.Content = map[string]*MediaType{
+				jsonContentType: {Schema: schema},
+			}
+		}
+		operationOf(h).responses[statusCode] = res
+		return nil
+	}
+}
+
+// Deprecated option marks the operation of the route as deprecated
+func Deprecated() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		operationOf(h).deprecated = true
+		return nil
+	}
+}
+
+// Hidden option excludes the route from the generated document
+func Hidden() compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		operationOf(h).hidden = true
+		return nil
+	}
+}
+
+// Generate builds an OpenAPI document from the given routes
+func Generate(routes []compass.RouteInfo, info Info) *Document {
+	doc := &Document{
+		OpenAPI: version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+
+	for _, route := range routes {
+		op, _ := route.Setting(operationKey).(*operation)
+		if op == nil {
+			op = newOperation()
+		}
+		if op.hidden {
+			continue
+		}
+
+		path, params := convertPattern(route.Pattern)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		// the content negotiated routes of the same method and path share an
+		// operation, the first registered one describes it
+		method := strings.ToLower(route.Method)
+		if _, exists := (*item)[method]; !exists {
+			(*item)[method] = op.build(route.Name, params)
+		}
+	}
+	return doc
+}
+
+// Handler returns an http.Handler which serves the OpenAPI document of the
+// routes, the document is generated on each request to reflect the latest
+// registrations
+func Handler(routes interface{ Routes() []compass.RouteInfo }, info Info) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := json.Marshal(Generate(routes.Routes(), info))
+		if err != nil {
+			http.Error(rw,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		rw.Header().Set("Content-Type", jsonContentType)
+		_, _ = rw.Write(body)
+	})
+}
+
+func newOperation() *operation {
+	return &operation{responses: make(map[int]*ResponseObject)}
+}
+
+func operationOf(h *chandler.Handler) *operation {
+	op, ok := h.Setting(operationKey).(*operation)
+	if !ok {
+		op = newOperation()
+		h.SetSetting(operationKey, op)
+	}
+	return op
+}
+
+func (op *operation) build(name string, params []string) *Operation {
+	o := &Operation{
+		OperationID: name,
+		Summary:     op.summary,
+		Description: op.description,
+		Tags:        op.tags,
+		Responses:   make(map[string]*ResponseObject, len(op.responses)),
+		Deprecated:  op.deprecated,
+	}
+	for _, p := range params {
+		o.Parameters = append(o.Parameters, Parameter{
+			Name:     p,
+			In:       "path",
+			Required: true,
+			Schema:   String(),
+		})
+	}
+	if op.requestBody != nil {
+		o.RequestBody = &RequestBodyObject{
+			Required: true,
+			Content: map[string]*MediaType{
+				jsonContentType: {Schema: op.requestBody},
+			},
+		}
+	}
+	for code, res := range op.responses {
+		o.Responses[strconv.Itoa(code)] = res
+	}
+	if len(o.Responses) == 0 {
+		o.Responses["default"] = &ResponseObject{Description: "Default response"}
+	}
+	return o
+}
+
+// convertPattern converts compass path params into OpenAPI path templates
+// like `/posts/:id` to `/posts/{id}` and returns the param names
+func convertPattern(pattern string) (string, []string) {
+	segments := strings.Split(pattern, "/")
+	params := make([]string, 0)
+	for i, segment := range segments {
+		if len(segment) > 0 && segment[0] == pathvar {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
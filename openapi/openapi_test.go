@@ -0,0 +1,134 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mustafaturan/compass"
+)
+
+func TestGenerate(t *testing.T) {
+	r, _ := compass.New()
+	_ = r.Get("/posts", fakeHandler{},
+		Summary("List posts"),
+		Tags("posts"),
+		Response(200, "Posts", Array(String())),
+		compass.Metadata("openapi", "custom"),
+	)
+	_ = r.Put("/posts/:id/comments/:commentID", fakeHandler{},
+		compass.Name("comments.update"),
+		Description("Updates a comment"),
+		RequestBody(Object(map[string]*Schema{"body": String()}, "body")),
+		Response(204, "Updated", nil),
+		Deprecated(),
+	)
+	_ = r.Get("/internal", fakeHandler{}, Hidden())
+
+	doc := Generate(r.Routes(), Info{Title: "Blog", Version: "1.0.0"})
+
+	t.Run("has document info", func(t *testing.T) {
+		if doc.OpenAPI != "3.0.3" || doc.Info.Title != "Blog" {
+			t.Fatalf("document info does not match: %+v", doc)
+		}
+	})
+
+	t.Run("excludes hidden routes", func(t *testing.T) {
+		if _, ok := doc.Paths["/internal"]; ok || len(doc.Paths) != 2 {
+			t.Fatalf("hidden routes must be excluded: %+v", doc.Paths)
+		}
+	})
+
+	t.Run("builds operations", func(t *testing.T) {
+		list := (*doc.Paths["/posts"])["get"]
+		want := &Operation{
+			Summary: "List posts",
+			Tags:    []string{"posts"},
+			Responses: map[string]*ResponseObject{
+				"200": {
+					Description: "Posts",
+					Content: map[string]*MediaType{
+						"application/json": {Schema: Array(String())},
+					},
+				},
+			},
+		}
+		if !reflect.DeepEqual(want, list) {
+			t.Fatalf("want: %+v, got: %+v", want, list)
+		}
+	})
+
+	t.Run("converts path params", func(t *testing.T) {
+		item, ok := doc.Paths["/posts/{id}/comments/{commentID}"]
+		if !ok {
+			t.Fatalf("path params must be converted: %+v", doc.Paths)
+		}
+		update := (*item)["put"]
+		want := []Parameter{
+			{Name: "id", In: "path", Required: true, Schema: String()},
+			{Name: "commentID", In: "path", Required: true, Schema: String()},
+		}
+		if !reflect.DeepEqual(want, update.Parameters) {
+			t.Fatalf("want: %+v, got: %+v", want, update.Parameters)
+		}
+		if update.OperationID != "comments.update" || !update.Deprecated {
+			t.Fatalf("operation attributes do not match: %+v", update)
+		}
+		if update.RequestBody == nil || !update.RequestBody.Required {
+			t.Fatalf("request body must be declared")
+		}
+		if update.Responses["204"].Content != nil {
+			t.Fatalf("response without schema must not have content")
+		}
+	})
+
+	t.Run("declares default response", func(t *testing.T) {
+		r, _ := compass.New()
+		_ = r.Delete("/posts/:id", fakeHandler{})
+		doc := Generate(r.Routes(), Info{})
+		op := (*doc.Paths["/posts/{id}"])["delete"]
+		if _, ok := op.Responses["default"]; !ok {
+			t.Fatalf("operations must have a default response")
+		}
+	})
+}
+
+func TestOptionErrors(t *testing.T) {
+	r, _ := compass.New()
+	if err := r.Get("/a", fakeHandler{}, RequestBody(nil)); err == nil {
+		t.Fatalf("nil request body schema must be rejected")
+	}
+	if err := r.Get("/b", fakeHandler{}, Response(0, "", nil)); err == nil {
+		t.Fatalf("invalid response status code must be rejected")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r, _ := compass.New()
+	info := Info{Title: "Blog", Version: "1.0.0"}
+	_ = r.Get("/openapi.json", Handler(r, info), Hidden())
+	_ = r.Get("/posts/:id", fakeHandler{}, Summary("Show post"))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://example.com/openapi.json", nil)
+	r.ServeHTTP(rw, req)
+
+	resp := rw.Result()
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("document must be served as JSON")
+	}
+
+	var doc Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("document must be valid JSON: %s", err)
+	}
+	if (*doc.Paths["/posts/{id}"])["get"].Summary != "Show post" {
+		t.Fatalf("document must include registered routes: %+v", doc.Paths)
+	}
+}
+
+type fakeHandler struct{}
+
+func (h fakeHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {}
@@ -0,0 +1,156 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a subset of OpenAPI 3.0 schema object
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// String returns a string schema
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Integer returns an integer schema
+func Integer() *Schema {
+	return &Schema{Type: "integer"}
+}
+
+// Number returns a number schema
+func Number() *Schema {
+	return &Schema{Type: "number"}
+}
+
+// Boolean returns a boolean schema
+func Boolean() *Schema {
+	return &Schema{Type: "boolean"}
+}
+
+// Array returns an array schema with the given item schema
+func Array(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Object returns an object schema with the given properties and required
+// property names
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required}
+}
+
+// WithFormat returns a copy of the schema with the given format
+func (s *Schema) WithFormat(format string) *Schema {
+	c := *s
+	c.Format = format
+	return &c
+}
+
+// WithDescription returns a copy of the schema with the given description
+func (s *Schema) WithDescription(description string) *Schema {
+	c := *s
+	c.Description = description
+	return &c
+}
+
+// SchemaOf builds a schema from the Go type of the given value using the
+// `json` struct tags for the property names. Fields without `omitempty` are
+// marked as required.
+func SchemaOf(v interface{}) *Schema {
+	return schemaOf(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+// schemaOf builds the schema of the type, the structs which are already being
+// built are described as plain objects to stop the recursion of the
+// self-referencing types
+func schemaOf(t reflect.Type, building map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Ptr {
+		s := schemaOf(t.Elem(), building)
+		s.Nullable = true
+		return s
+	}
+	if t == timeType {
+		return String().WithFormat("date-time")
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint,
+		reflect.Uint8, reflect.Uint16:
+		return Integer()
+	case reflect.Int32, reflect.Uint32:
+		return Integer().WithFormat("int32")
+	case reflect.Int64, reflect.Uint64:
+		return Integer().WithFormat("int64")
+	case reflect.Float32:
+		return Number().WithFormat("float")
+	case reflect.Float64:
+		return Number().WithFormat("double")
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return String().WithFormat("byte")
+		}
+		return Array(schemaOf(t.Elem(), building))
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if building[t] {
+			return &Schema{Type: "object"}
+		}
+		building[t] = true
+		defer delete(building, t)
+		return structSchemaOf(t, building)
+	}
+	return &Schema{}
+}
+
+func structSchemaOf(t reflect.Type, building map[reflect.Type]bool) *Schema {
+	s := Object(make(map[string]*Schema))
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name, omitempty := f.Name, false
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, p := range parts[1:] {
+				omitempty = omitempty || p == "omitempty"
+			}
+		}
+
+		s.Properties[name] = schemaOf(f.Type, building)
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaOf(t *testing.T) {
+	type author struct {
+		Name string `json:"name"`
+	}
+	type post struct {
+		ID        int64     `json:"id"`
+		Title     string    `json:"title"`
+		Tags      []string  `json:"tags,omitempty"`
+		Author    *author   `json:"author,omitempty"`
+		Published time.Time `json:"published_at"`
+		Score     float64
+		Ignored   string `json:"-"`
+		internal  string
+	}
+
+	got := SchemaOf(post{})
+	want := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":    {Type: "integer", Format: "int64"},
+			"title": {Type: "string"},
+			"tags":  {Type: "array", Items: &Schema{Type: "string"}},
+			"author": {
+				Type:       "object",
+				Properties: map[string]*Schema{"name": {Type: "string"}},
+				Required:   []string{"name"},
+				Nullable:   true,
+			},
+			"published_at": {Type: "string", Format: "date-time"},
+			"Score":        {Type: "number", Format: "double"},
+		},
+		Required: []string{"id", "title", "published_at", "Score"},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("want: %+v, got: %+v", want, got)
+	}
+}
+
+type node struct {
+	Name     string  `json:"name"`
+	Parent   *node   `json:"parent,omitempty"`
+	Children []*node `json:"children"`
+}
+
+func TestSchemaOfRecursiveTypes(t *testing.T) {
+	got := SchemaOf(node{})
+	want := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":     {Type: "string"},
+			"parent":   {Type: "object", Nullable: true},
+			"children": {Type: "array", Items: &Schema{Type: "object", Nullable: true}},
+		},
+		Required: []string{"name", "children"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestSchemaConstructors(t *testing.T) {
+	tests := []struct {
+		schema *Schema
+		want   *Schema
+	}{
+		{String(), &Schema{Type: "string"}},
+		{Integer(), &Schema{Type: "integer"}},
+		{Number(), &Schema{Type: "number"}},
+		{Boolean(), &Schema{Type: "boolean"}},
+		{Array(String()), &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+		{
+			Object(map[string]*Schema{"id": Integer()}, "id"),
+			&Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"id": {Type: "integer"}},
+				Required:   []string{"id"},
+			},
+		},
+		{
+			String().WithFormat("uuid").WithDescription("post id"),
+			&Schema{Type: "string", Format: "uuid", Description: "post id"},
+		},
+	}
+
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.want, test.schema) {
+			t.Fatalf("want: %+v, got: %+v", test.want, test.schema)
+		}
+	}
+}
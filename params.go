@@ -0,0 +1,197 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// PathParams provides typed access to the routing params
+type PathParams map[string]string
+
+// UUID is a 128 bit universally unique identifier
+type UUID [16]byte
+
+// ParamError is returned when a param is missing or can't be converted to the
+// requested type
+type ParamError struct {
+	Name  string
+	Value string
+	Type  string
+	Err   error
+}
+
+// ErrParamNotFound is returned when the requested param does not exist
+var ErrParamNotFound = errors.New("param not found")
+
+var errInvalidUUID = errors.New("invalid UUID format")
+
+// Error implements error interface
+func (e *ParamError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("param %q: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf(
+		"param %q: can't convert %q to %s: %s",
+		e.Name,
+		e.Value,
+		e.Type,
+		e.Err,
+	)
+}
+
+// Unwrap returns the underlying error
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// ParamsFromContext returns routing params from the context, the second return
+// value reports whether the params exist in the context
+func ParamsFromContext(ctx context.Context) (PathParams, bool) {
+	params, ok := ctx.Value(CtxParams).(map[string]string)
+	return PathParams(params), ok
+}
+
+// WithParams returns a copy of the context with the given routing params, it
+// is useful to test handlers without a router
+func WithParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, CtxParams, params)
+}
+
+// String returns the param value
+func (p PathParams) String(name string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return "", &ParamError{Name: name, Err: ErrParamNotFound}
+	}
+	return v, nil
+}
+
+// Int returns the param value as int
+func (p PathParams) Int(name string) (int, error) {
+	v, err := p.parse(name, "int", func(v string) (interface{}, error) {
+		return strconv.Atoi(v)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+// Int64 returns the param value as int64
+func (p PathParams) Int64(name string) (int64, error) {
+	v, err := p.parse(name, "int64", func(v string) (interface{}, error) {
+		return strconv.ParseInt(v, 10, 64)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+// Uint returns the param value as uint
+func (p PathParams) Uint(name string) (uint, error) {
+	v, err := p.parse(name, "uint", func(v string) (interface{}, error) {
+		u, err := strconv.ParseUint(v, 10, 0)
+		return uint(u), err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint), nil
+}
+
+// Bool returns the param value as bool
+func (p PathParams) Bool(name string) (bool, error) {
+	v, err := p.parse(name, "bool", func(v string) (interface{}, error) {
+		return strconv.ParseBool(v)
+	})
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// UUID returns the param value as UUID
+func (p PathParams) UUID(name string) (UUID, error) {
+	v, err := p.parse(name, "UUID", func(v string) (interface{}, error) {
+		return ParseUUID(v)
+	})
+	if err != nil {
+		return UUID{}, err
+	}
+	return v.(UUID), nil
+}
+
+// Time returns the param value as time.Time parsed with the given layout
+func (p PathParams) Time(name, layout string) (time.Time, error) {
+	v, err := p.parse(name, "time", func(v string) (interface{}, error) {
+		return time.Parse(layout, v)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return v.(time.Time), nil
+}
+
+func (p PathParams) parse(
+	name, typ string,
+	convert func(string) (interface{}, error),
+) (interface{}, error) {
+	v, err := p.String(name)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := convert(v)
+	if err != nil {
+		return nil, &ParamError{
+			Name:  name,
+			Value: v,
+			Type:  typ,
+			Err:   numError(err),
+		}
+	}
+	return converted, nil
+}
+
+// ParseUUID parses UUIDs in the canonical `xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx`
+// format or as 32 hex chars without hyphens
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	switch len(s) {
+	case 32:
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, errInvalidUUID
+		}
+		s = s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	default:
+		return u, errInvalidUUID
+	}
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return u, errInvalidUUID
+	}
+	return u, nil
+}
+
+// String returns the canonical representation of the UUID
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
@@ -0,0 +1,138 @@
+package compass
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParamsFromContext(t *testing.T) {
+	t.Run("without params", func(t *testing.T) {
+		if _, ok := ParamsFromContext(context.Background()); ok {
+			t.Fatalf("must report missing params")
+		}
+	})
+
+	t.Run("with params", func(t *testing.T) {
+		ctx := WithParams(context.Background(), map[string]string{"id": "1"})
+		params, ok := ParamsFromContext(ctx)
+		if !ok || params["id"] != "1" {
+			t.Fatalf("must return params from the context, got %+v", params)
+		}
+		if Params(ctx)["id"] != "1" {
+			t.Fatalf("params must be accessible with Params")
+		}
+	})
+}
+
+func TestPathParams(t *testing.T) {
+	params := PathParams{
+		"id":      "42",
+		"big":     "9223372036854775807",
+		"neg":     "-1",
+		"flag":    "true",
+		"name":    "compass",
+		"uuid":    "f47ac10b-58cc-4372-a567-0e02b2c3d479",
+		"rawuuid": "f47ac10b58cc4372a5670e02b2c3d479",
+		"date":    "2021-02-03",
+	}
+
+	t.Run("converts values", func(t *testing.T) {
+		if v, err := params.String("name"); v != "compass" || err != nil {
+			t.Fatalf("String() = %v, %v", v, err)
+		}
+		if v, err := params.Int("id"); v != 42 || err != nil {
+			t.Fatalf("Int() = %v, %v", v, err)
+		}
+		if v, err := params.Int64("big"); v != 9223372036854775807 || err != nil {
+			t.Fatalf("Int64() = %v, %v", v, err)
+		}
+		if v, err := params.Uint("id"); v != 42 || err != nil {
+			t.Fatalf("Uint() = %v, %v", v, err)
+		}
+		if v, err := params.Bool("flag"); !v || err != nil {
+			t.Fatalf("Bool() = %v, %v", v, err)
+		}
+		want := time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)
+		if v, err := params.Time("date", "2006-01-02"); !v.Equal(want) || err != nil {
+			t.Fatalf("Time() = %v, %v", v, err)
+		}
+		for _, name := range []string{"uuid", "rawuuid"} {
+			v, err := params.UUID(name)
+			if v.String() != "f47ac10b-58cc-4372-a567-0e02b2c3d479" || err != nil {
+				t.Fatalf("UUID() = %v, %v", v, err)
+			}
+		}
+	})
+
+	t.Run("returns typed errors", func(t *testing.T) {
+		tests := []struct {
+			fn      func() error
+			wantErr error
+			message string
+		}{
+			{
+				fn:      func() error { _, err := params.String("missing"); return err },
+				wantErr: ErrParamNotFound,
+				message: `param "missing": param not found`,
+			},
+			{
+				fn:      func() error { _, err := params.Int("name"); return err },
+				wantErr: strconv.ErrSyntax,
+				message: `param "name": can't convert "compass" to int: invalid syntax`,
+			},
+			{
+				fn:      func() error { _, err := params.Uint("neg"); return err },
+				wantErr: strconv.ErrSyntax,
+			},
+			{
+				fn:      func() error { _, err := params.Int64("missing"); return err },
+				wantErr: ErrParamNotFound,
+			},
+			{
+				fn:      func() error { _, err := params.Bool("id"); return err },
+				wantErr: strconv.ErrSyntax,
+			},
+			{
+				fn:      func() error { _, err := params.UUID("name"); return err },
+				wantErr: errInvalidUUID,
+			},
+			{
+				fn: func() error { _, err := params.Time("name", time.RFC3339); return err },
+				message: `param "name": can't convert "compass" to time: ` +
+					`parsing time "compass" as "2006-01-02T15:04:05Z07:00": ` +
+					`cannot parse "compass" as "2006"`,
+			},
+		}
+
+		for _, test := range tests {
+			err := test.fn()
+			var paramErr *ParamError
+			if !errors.As(err, &paramErr) {
+				t.Fatalf("must return ParamError, got %v", err)
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("want err(%v), got err(%v)", test.wantErr, err)
+			}
+			if test.message != "" && err.Error() != test.message {
+				t.Fatalf("want message(%s), got (%s)", test.message, err)
+			}
+		}
+	})
+}
+
+func TestParseUUID(t *testing.T) {
+	tests := []string{
+		"",
+		"f47ac10b-58cc-4372-a567-0e02b2c3d47",
+		"f47ac10b_58cc_4372_a567_0e02b2c3d479",
+		"z47ac10b-58cc-4372-a567-0e02b2c3d479",
+	}
+	for _, test := range tests {
+		if _, err := ParseUUID(test); err == nil {
+			t.Fatalf("ParseUUID(%s) must fail", test)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"errors"
+	"net/http"
+
+	chandler "github.com/mustafaturan/compass/handler"
+)
+
+// Header route option makes the route match only the requests with the header
+// value, an empty value matches any value of the header
+func Header(key, value string) RouteOption {
+	if key == "" {
+		return invalidPredicate("header key can't be empty")
+	}
+	key = http.CanonicalHeaderKey(key)
+	return When("header "+key+"="+value, func(req *http.Request) bool {
+		values, ok := req.Header[key]
+		if value == "" {
+			return ok
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Query route option makes the route match only the requests with the query
+// param value, an empty value matches any value of the query param
+func Query(key, value string) RouteOption {
+	if key == "" {
+		return invalidPredicate("query key can't be empty")
+	}
+	return When("query "+key+"="+value, func(req *http.Request) bool {
+		values, ok := req.URL.Query()[key]
+		if value == "" {
+			return ok
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// When route option makes the route match only the requests which satisfy
+// the named condition. The routes of the same method and path are evaluated
+// in the registration order and the requests fall through to the next route
+// when a condition doesn't hold. The names must be unique per route and are
+// listed in the route introspection.
+func When(name string, fn func(req *http.Request) bool) RouteOption {
+	return func(h *chandler.Handler) error {
+		if name == "" {
+			return errors.New("predicate name can't be empty")
+		}
+		if fn == nil {
+			return errors.New("predicate func can't be nil")
+		}
+		h.Predicates = append(h.Predicates, chandler.Predicate{Name: name, Match: fn})
+		return nil
+	}
+}
+
+func invalidPredicate(message string) RouteOption {
+	return func(*chandler.Handler) error {
+		return errors.New(message)
+	}
+}
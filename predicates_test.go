@@ -0,0 +1,90 @@
+package compass
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPredicates(t *testing.T) {
+	isAdmin := func(req *http.Request) bool {
+		return req.Header.Get("X-Role") == "admin"
+	}
+
+	r, _ := New()
+	_ = r.Get("/items", fakeHandler{"v2"}, Header("x-api-version", "2"))
+	_ = r.Get("/items", fakeHandler{"xml"}, Query("format", "xml"))
+	_ = r.Get("/items", fakeHandler{"admin"}, When("admin", isAdmin))
+	_ = r.Get("/items", fakeHandler{"default"})
+	_ = r.Get("/debug", fakeHandler{"debug"}, Query("debug", ""), Header("X-Debug", ""))
+
+	tests := []struct {
+		path       string
+		headers    map[string]string
+		statusCode int
+		body       string
+	}{
+		{"/items", nil, http.StatusOK, "default"},
+		{"/items", map[string]string{"X-API-Version": "2"}, http.StatusOK, "v2"},
+		{"/items", map[string]string{"X-API-Version": "3"}, http.StatusOK, "default"},
+		{"/items?format=xml", nil, http.StatusOK, "xml"},
+		{"/items?format=xml", map[string]string{"X-API-Version": "2"}, http.StatusOK, "v2"},
+		{"/items", map[string]string{"X-Role": "admin"}, http.StatusOK, "admin"},
+		{"/debug?debug", map[string]string{"X-Debug": "1"}, http.StatusOK, "debug"},
+		{"/debug?debug", nil, http.StatusNotFound, "Not Found\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Code != test.statusCode {
+				t.Fatalf("want status code %d, but got %d", test.statusCode, rw.Code)
+			}
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("lists predicates in route info", func(t *testing.T) {
+		want := [][]string{
+			{"header X-Api-Version=2"},
+			{"query format=xml"},
+			{"admin"},
+			nil,
+			{"query debug=", "header X-Debug="},
+		}
+		for i, route := range r.Routes() {
+			if !reflect.DeepEqual(route.Predicates, want[i]) {
+				t.Fatalf("want predicates %v, got %v", want[i], route.Predicates)
+			}
+		}
+	})
+
+	t.Run("rejects invalid predicates", func(t *testing.T) {
+		options := []RouteOption{
+			Header("", "1"),
+			Query("", "1"),
+			When("", isAdmin),
+			When("admin", nil),
+		}
+		for _, o := range options {
+			if err := r.Get("/invalid", fakeHandler{"ok"}, o); err == nil {
+				t.Fatalf("invalid predicate must be rejected")
+			}
+		}
+	})
+
+	t.Run("rejects duplicate predicates", func(t *testing.T) {
+		if err := r.Get("/items", fakeHandler{"v2"}, Header("X-API-Version", "2")); err == nil {
+			t.Fatalf("route with the same predicates must be rejected")
+		}
+	})
+}
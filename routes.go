@@ -0,0 +1,223 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+package compass
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// Registry holds the named handlers and interceptors which can be referenced
+// by the route definitions
+type Registry struct {
+	Handlers     map[string]http.Handler
+	Interceptors map[string]cinterceptor.Interceptor
+}
+
+// RouteDefinition is a declarative route entry
+type RouteDefinition struct {
+	Method       string   `json:"method"`
+	Path         string   `json:"path"`
+	Handler      string   `json:"handler"`
+	Name         string   `json:"name,omitempty"`
+	Interceptors []string `json:"interceptors,omitempty"`
+}
+
+// DefinitionError is an error for a route definition with its line context
+type DefinitionError struct {
+	Line int
+	Err  error
+}
+
+// LoadError holds all the errors found while loading route definitions
+type LoadError struct {
+	Errors []error
+}
+
+// Error implements error interface
+func (e *DefinitionError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+// Error implements error interface
+func (e *LoadError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "route definitions are invalid: " + strings.Join(messages, "; ")
+}
+
+// LoadRoutes option reads JSON route definitions from the given reader,
+// resolves the referenced handlers and interceptors from the registry and
+// registers the routes. The definitions are expected to be a JSON array:
+//
+//	[
+//		{
+//			"method": "GET",
+//			"path": "/posts/:id",
+//			"handler": "showPost",
+//			"name": "posts.show",
+//			"interceptors": ["auth"]
+//		}
+//	]
+//
+// All definitions are validated before any route gets registered and all
+// problems are reported together as *LoadError.
+func LoadRoutes(rd io.Reader, registry Registry) Option {
+	return func(r *router) error {
+		data, err := ioutil.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+
+		definitions, lines, err := decodeRouteDefinitions(data)
+		if err != nil {
+			return err
+		}
+
+		loadErr := &LoadError{}
+		options := make([][]RouteOption, len(definitions))
+		for i, d := range definitions {
+			opts, errs := d.resolve(registry)
+			for _, err := range errs {
+				loadErr.append(lines[i], err)
+			}
+			options[i] = opts
+		}
+		if len(loadErr.Errors) > 0 {
+			return loadErr
+		}
+
+		for i, d := range definitions {
+			handler := registry.Handlers[d.Handler]
+			err := r.registerHandler(d.Method, d.Path, handler, options[i]...)
+			if err != nil {
+				loadErr.append(lines[i], err)
+			}
+		}
+		if len(loadErr.Errors) > 0 {
+			return loadErr
+		}
+		return nil
+	}
+}
+
+func (e *LoadError) append(line int, err error) {
+	e.Errors = append(e.Errors, &DefinitionError{Line: line, Err: err})
+}
+
+func (d RouteDefinition) resolve(registry Registry) ([]RouteOption, []error) {
+	var errs []error
+	if !isSupportedMethod(d.Method) {
+		errs = append(errs, fmt.Errorf("method %q is not supported", d.Method))
+	}
+	if d.Path == "" {
+		errs = append(errs, errors.New("path can't be empty"))
+	}
+	if _, ok := registry.Handlers[d.Handler]; !ok {
+		errs = append(errs, fmt.Errorf("handler %q is not registered", d.Handler))
+	}
+
+	options := make([]RouteOption, 0, 2)
+	if d.Name != "" {
+		options = append(options, Name(d.Name))
+	}
+	interceptors := make([]cinterceptor.Interceptor, 0, len(d.Interceptors))
+	for _, name := range d.Interceptors {
+		i, ok := registry.Interceptors[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("interceptor %q is not registered", name))
+			continue
+		}
+		interceptors = append(interceptors, i)
+	}
+	if len(interceptors) > 0 {
+		options = append(options, Interceptors(interceptors...))
+	}
+	return options, errs
+}
+
+// decodeRouteDefinitions decodes the definitions and the line numbers where
+// each definition starts
+func decodeRouteDefinitions(data []byte) ([]RouteDefinition, []int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, nil, &LoadError{Errors: []error{&DefinitionError{
+			Line: lineAt(data, 0),
+			Err:  errors.New("route definitions must be a JSON array"),
+		}}}
+	}
+
+	definitions := make([]RouteDefinition, 0)
+	lines := make([]int, 0)
+	loadErr := &LoadError{}
+	for dec.More() {
+		start := skipSpaces(data, dec.InputOffset())
+		var d RouteDefinition
+		if err := dec.Decode(&d); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				loadErr.append(lineAt(data, syntaxErr.Offset), err)
+				return nil, nil, loadErr
+			}
+			loadErr.append(lineAt(data, start), err)
+			continue
+		}
+		definitions = append(definitions, d)
+		lines = append(lines, lineAt(data, start))
+	}
+	if _, err := dec.Token(); err != nil {
+		loadErr.append(lineAt(data, dec.InputOffset()), err)
+	}
+	if len(loadErr.Errors) > 0 {
+		return nil, nil, loadErr
+	}
+	return definitions, lines, nil
+}
+
+func skipSpaces(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
+
+func isSupportedMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package compass
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+func TestLoadRoutes(t *testing.T) {
+	registry := Registry{
+		Handlers: map[string]http.Handler{
+			"listPosts": fakeHandler{"list"},
+			"showPost":  fakeHandler{"show"},
+		},
+		Interceptors: map[string]cinterceptor.Interceptor{
+			"header": cinterceptor.Func(func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					rw.Header().Set("X-Route", "loaded")
+					h.ServeHTTP(rw, req)
+				})
+			}),
+		},
+	}
+
+	t.Run("registers valid definitions", func(t *testing.T) {
+		definitions := `[
+			{"method": "GET", "path": "/posts", "handler": "listPosts"},
+			{
+				"method": "GET",
+				"path": "/posts/:id",
+				"handler": "showPost",
+				"name": "posts.show",
+				"interceptors": ["header"]
+			}
+		]`
+		r, err := New(LoadRoutes(strings.NewReader(definitions), registry))
+		if err != nil {
+			t.Fatalf("must load routes without error but got %s", err)
+		}
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/posts/1", nil)
+		r.ServeHTTP(rw, req)
+
+		resp := rw.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("want status code 200, but got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("X-Route") != "loaded" {
+			t.Fatalf("route interceptors must be applied")
+		}
+		if _, ok := r.(*router).names["posts.show"]; !ok {
+			t.Fatalf("route name must be registered")
+		}
+	})
+
+	t.Run("reports all errors with line context", func(t *testing.T) {
+		definitions := `[
+			{"method": "GET", "path": "/posts", "handler": "missing"},
+			{"method": "FETCH", "path": "/posts", "handler": "listPosts"},
+			{
+				"method": "GET",
+				"path": "/posts/:id",
+				"handler": "showPost",
+				"interceptors": ["unknown"]
+			}
+		]`
+		_, err := New(LoadRoutes(strings.NewReader(definitions), registry))
+
+		var loadErr *LoadError
+		if !errors.As(err, &loadErr) {
+			t.Fatalf("must return LoadError but got %v", err)
+		}
+		want := []string{
+			`line 2: handler "missing" is not registered`,
+			`line 3: method "FETCH" is not supported`,
+			`line 4: interceptor "unknown" is not registered`,
+		}
+		if len(loadErr.Errors) != len(want) {
+			t.Fatalf("want %d errors, got %v", len(want), loadErr.Errors)
+		}
+		for i, msg := range want {
+			if loadErr.Errors[i].Error() != msg {
+				t.Fatalf("want err(%s), got err(%s)", msg, loadErr.Errors[i])
+			}
+		}
+	})
+
+	t.Run("reports unknown fields and registration errors", func(t *testing.T) {
+		definitions := `[
+			{"method": "GET", "path": "/posts", "handler": "listPosts"},
+			{"method": "GET", "path": "/posts", "handler": "showPost"},
+			{"method": "GET", "path": "/p", "handler": "showPost", "x": 1}
+		]`
+		_, err := New(LoadRoutes(strings.NewReader(definitions), registry))
+
+		var loadErr *LoadError
+		if !errors.As(err, &loadErr) || len(loadErr.Errors) != 1 {
+			t.Fatalf("must return LoadError with 1 error but got %v", err)
+		}
+		if !strings.HasPrefix(loadErr.Errors[0].Error(), "line 4: ") {
+			t.Fatalf("unknown field must be reported with line, got %s", err)
+		}
+
+		definitions = `[
+			{"method": "GET", "path": "/posts", "handler": "listPosts"},
+			{"method": "GET", "path": "/posts", "handler": "showPost"}
+		]`
+		_, err = New(LoadRoutes(strings.NewReader(definitions), registry))
+		want := "route definitions are invalid: " +
+			"line 3: path is already registered for another handler"
+		if err == nil || err.Error() != want {
+			t.Fatalf("want err(%s), got err(%v)", want, err)
+		}
+	})
+
+	t.Run("reports syntax errors with line context", func(t *testing.T) {
+		definitions := "[\n{\"method\": \"GET\",\n\"path\" \"/posts\"}\n]"
+		_, err := New(LoadRoutes(strings.NewReader(definitions), registry))
+		if err == nil || !strings.Contains(err.Error(), "line 3: ") {
+			t.Fatalf("syntax error must be reported with line, got %v", err)
+		}
+
+		_, err = New(LoadRoutes(strings.NewReader(`{}`), registry))
+		if err == nil || !strings.Contains(err.Error(), "must be a JSON array") {
+			t.Fatalf("non array definitions must be rejected, got %v", err)
+		}
+	})
+}
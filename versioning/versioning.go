@@ -0,0 +1,256 @@
+// Copyright 2021 Mustafa Turan. All rights reserved.
+// Use of this source code is governed by a Apache License 2.0 license that can
+// be found in the LICENSE file.
+
+// Package versioning routes the same logical paths to different handlers by
+// the API version of the requests. The version can be carried by a path
+// prefix, a vendor media type of the Accept header or a request header.
+package versioning
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mustafaturan/compass"
+	chandler "github.com/mustafaturan/compass/handler"
+	cinterceptor "github.com/mustafaturan/compass/interceptor"
+)
+
+// Versioning registers the routes of the API versions
+type Versioning struct {
+	strategy       Strategy
+	defaultVersion string
+	deprecations   map[string]deprecation
+}
+
+// Option is a versioning option
+type Option func(*Versioning) error
+
+// Strategy extracts the requested version from the requests
+type Strategy interface {
+	// Version returns the requested version, it is empty when the request
+	// doesn't carry a version
+	Version(req *http.Request) string
+
+	// Header returns the request header which carries the version, it is
+	// empty when the version isn't carried by a header
+	Header() string
+}
+
+// deprecation is the deprecation info of a version
+type deprecation struct {
+	deprecatedAt time.Time
+	sunset       time.Time
+}
+
+type pathStrategy struct{}
+
+type headerStrategy struct {
+	name string
+}
+
+type mediaTypeStrategy struct {
+	prefix string
+}
+
+// settingKey is the type of the route setting keys
+type settingKey int8
+
+const (
+	settingVersion = settingKey(0)
+
+	headerDeprecation = "Deprecation"
+	headerSunset      = "Sunset"
+	headerVary        = "Vary"
+
+	pathPrefix = "v"
+)
+
+// New returns a new versioning with the strategy
+func New(strategy Strategy, options ...Option) (*Versioning, error) {
+	if strategy == nil {
+		return nil, errors.New("strategy can't be nil")
+	}
+	v := &Versioning{
+		strategy:     strategy,
+		deprecations: make(map[string]deprecation),
+	}
+
+	for _, o := range options {
+		if err := o(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// WithDefault option sets the version of the requests which don't carry a
+// version, the requests without version aren't routed by default. It has no
+// effect on the Path strategy since the paths of the versions differ.
+func WithDefault(version string) Option {
+	return func(v *Versioning) error {
+		if version == "" {
+			return errors.New("default version can't be empty")
+		}
+		v.defaultVersion = version
+		return nil
+	}
+}
+
+// WithDeprecated option deprecates the version, the responses of the version
+// have `Deprecation` header with the deprecation date or `true` when the date
+// is zero and `Sunset` header when the sunset date isn't zero
+func WithDeprecated(version string, deprecatedAt, sunset time.Time) Option {
+	return func(v *Versioning) error {
+		if version == "" {
+			return errors.New("deprecated version can't be empty")
+		}
+		v.deprecations[version] = deprecation{
+			deprecatedAt: deprecatedAt,
+			sunset:       sunset,
+		}
+		return nil
+	}
+}
+
+// Path strategy reads the version from the first path segment like `/v2/...`
+func Path() Strategy {
+	return pathStrategy{}
+}
+
+// Header strategy reads the version from the request header
+func Header(name string) Strategy {
+	return headerStrategy{name: http.CanonicalHeaderKey(name)}
+}
+
+// MediaType strategy reads the version from the vendor media types of the
+// Accept header like `application/vnd.acme.v2+json` for `acme` vendor
+func MediaType(vendor string) Strategy {
+	return mediaTypeStrategy{prefix: "application/vnd." + strings.ToLower(vendor) + ".v"}
+}
+
+// Version route option makes the route match only the requests of the version
+// and adds the deprecation headers to the responses of deprecated versions
+func (v *Versioning) Version(version string) compass.RouteOption {
+	return func(h *chandler.Handler) error {
+		if version == "" {
+			return errors.New("version can't be empty")
+		}
+		if h.Setting(settingVersion) != nil {
+			return errors.New("route version is already set")
+		}
+		err := compass.When("version "+version, func(req *http.Request) bool {
+			requested := v.strategy.Version(req)
+			if requested == "" {
+				requested = v.defaultVersion
+			}
+			return requested == version
+		})(h)
+		if err != nil {
+			return err
+		}
+		h.SetSetting(settingVersion, version)
+		return compass.Interceptors(v.interceptor(version))(h)
+	}
+}
+
+// VersionOf returns the version of the route, it is empty for the routes
+// without a version
+func VersionOf(route compass.RouteInfo) string {
+	version, _ := route.Setting(settingVersion).(string)
+	return version
+}
+
+// Router returns a route group for the version, the routes are registered
+// under `/v{version}` prefix for the Path strategy
+func (v *Versioning) Router(
+	r compass.Router,
+	version string,
+	options ...compass.RouteOption,
+) compass.Router {
+	prefix := ""
+	if _, ok := v.strategy.(pathStrategy); ok {
+		prefix = "/" + pathPrefix + version
+	}
+	opts := make([]compass.RouteOption, 0, len(options)+1)
+	opts = append(opts, v.Version(version))
+	opts = append(opts, options...)
+	return r.Group(prefix, opts...)
+}
+
+// interceptor adds the Vary header for the header carried versions and the
+// deprecation headers for the deprecated versions
+func (v *Versioning) interceptor(version string) cinterceptor.Interceptor {
+	d, deprecated := v.deprecations[version]
+	deprecatedAt := "true"
+	if !d.deprecatedAt.IsZero() {
+		deprecatedAt = d.deprecatedAt.UTC().Format(http.TimeFormat)
+	}
+	vary := v.strategy.Header()
+	return cinterceptor.Func(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			header := rw.Header()
+			if vary != "" {
+				header.Add(headerVary, vary)
+			}
+			if deprecated {
+				header.Set(headerDeprecation, deprecatedAt)
+				if !d.sunset.IsZero() {
+					header.Set(headerSunset, d.sunset.UTC().Format(http.TimeFormat))
+				}
+			}
+			h.ServeHTTP(rw, req)
+		})
+	})
+}
+
+// Version implements Strategy interface
+func (pathStrategy) Version(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	segment := strings.SplitN(path, "/", 2)[0]
+	if len(segment) <= len(pathPrefix) || !strings.HasPrefix(segment, pathPrefix) {
+		return ""
+	}
+	return segment[len(pathPrefix):]
+}
+
+// Header implements Strategy interface
+func (pathStrategy) Header() string {
+	return ""
+}
+
+// Version implements Strategy interface
+func (s headerStrategy) Version(req *http.Request) string {
+	return strings.TrimSpace(req.Header.Get(s.name))
+}
+
+// Header implements Strategy interface
+func (s headerStrategy) Header() string {
+	return s.name
+}
+
+// Version implements Strategy interface
+func (s mediaTypeStrategy) Version(req *http.Request) string {
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(part, ";")[0]))
+		if !strings.HasPrefix(mediaType, s.prefix) {
+			continue
+		}
+		version := mediaType[len(s.prefix):]
+		if i := strings.IndexByte(version, '+'); i >= 0 {
+			version = version[:i]
+		}
+		if version != "" {
+			return version
+		}
+	}
+	return ""
+}
+
+// Header implements Strategy interface
+func (mediaTypeStrategy) Header() string {
+	return "Accept"
+}
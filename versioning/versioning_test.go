@@ -0,0 +1,141 @@
+package versioning
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mustafaturan/compass"
+)
+
+type fakeHandler struct {
+	body string
+}
+
+func (h fakeHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	_, _ = rw.Write([]byte(h.body))
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		strategy Strategy
+		option   Option
+		err      string
+	}{
+		{nil, WithDefault("1"), "strategy can't be nil"},
+		{Path(), WithDefault(""), "default version can't be empty"},
+		{Path(), WithDeprecated("", time.Time{}, time.Time{}), "deprecated version can't be empty"},
+	}
+	for _, test := range tests {
+		if _, err := New(test.strategy, test.option); err == nil || err.Error() != test.err {
+			t.Fatalf("want err(%s), got err(%v)", test.err, err)
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	sunset := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	deprecatedAt := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		strategy  Strategy
+		path      string
+		headers   map[string]string
+		body      string
+		deprecate string
+		sunset    string
+		vary      string
+	}{
+		{"path v1", Path(), "/v1/items", nil, "v1", "Tue, 01 Jun 2021 00:00:00 GMT",
+			"Sat, 01 Jan 2022 00:00:00 GMT", ""},
+		{"path v2", Path(), "/v2/items", nil, "v2", "", "", ""},
+		{"path without version", Path(), "/items", nil, "Not Found\n", "", "", ""},
+		{"header v2", Header("x-api-version"), "/items",
+			map[string]string{"X-API-Version": "2"}, "v2", "", "", "X-Api-Version"},
+		{"header default", Header("X-API-Version"), "/items",
+			nil, "v1", "Tue, 01 Jun 2021 00:00:00 GMT",
+			"Sat, 01 Jan 2022 00:00:00 GMT", "X-Api-Version"},
+		{"header unknown", Header("X-API-Version"), "/items",
+			map[string]string{"X-API-Version": "9"}, "Not Found\n", "", "", ""},
+		{"media type v2", MediaType("acme"), "/items",
+			map[string]string{"Accept": "text/html, application/vnd.acme.v2+json;q=0.9"},
+			"v2", "", "", "Accept"},
+		{"media type default", MediaType("acme"), "/items",
+			map[string]string{"Accept": "application/json"},
+			"v1", "Tue, 01 Jun 2021 00:00:00 GMT",
+			"Sat, 01 Jan 2022 00:00:00 GMT", "Accept"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, _ := New(test.strategy,
+				WithDefault("1"),
+				WithDeprecated("1", deprecatedAt, sunset),
+			)
+			r, _ := compass.New()
+			if err := v.Router(r, "1").Get("/items", fakeHandler{"v1"}); err != nil {
+				t.Fatalf("v1 route must be registered, got %s", err)
+			}
+			if err := v.Router(r, "2").Get("/items", fakeHandler{"v2"}); err != nil {
+				t.Fatalf("v2 route must be registered, got %s", err)
+			}
+
+			req := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+			for k, val := range test.headers {
+				req.Header.Set(k, val)
+			}
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			if rw.Body.String() != test.body {
+				t.Fatalf("want body %q, but got %q", test.body, rw.Body.String())
+			}
+			want := map[string]string{
+				"Deprecation": test.deprecate,
+				"Sunset":      test.sunset,
+				"Vary":        test.vary,
+			}
+			for k, val := range want {
+				if got := rw.Header().Get(k); got != val {
+					t.Fatalf("want %s header %q, but got %q", k, val, got)
+				}
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	v, _ := New(Header("X-API-Version"), WithDeprecated("1", time.Time{}, time.Time{}))
+	r, _ := compass.New()
+	_ = r.Get("/items", fakeHandler{"v1"}, v.Version("1"))
+
+	t.Run("marks deprecated versions", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/items", nil)
+		req.Header.Set("X-API-Version", "1")
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		if rw.Header().Get("Deprecation") != "true" || rw.Header().Get("Sunset") != "" {
+			t.Fatalf("want deprecation without date, got %v", rw.Header())
+		}
+	})
+
+	t.Run("exposes route version", func(t *testing.T) {
+		if got := VersionOf(r.Routes()[0]); got != "1" {
+			t.Fatalf("want route version 1, got %v", got)
+		}
+		if err := r.Get("/metadata", fakeHandler{}, compass.Metadata("version", "x"), v.Version("3")); err != nil {
+			t.Fatalf("version metadata must not collide with route version: %s", err)
+		}
+	})
+
+	t.Run("rejects invalid versions", func(t *testing.T) {
+		if err := r.Get("/empty", fakeHandler{}, v.Version("")); err == nil {
+			t.Fatalf("empty version must be rejected")
+		}
+		if err := r.Get("/twice", fakeHandler{}, v.Version("1"), v.Version("2")); err == nil {
+			t.Fatalf("second version must be rejected")
+		}
+	})
+}